@@ -0,0 +1,120 @@
+package compressor
+
+import (
+	"errors"
+	"io"
+)
+
+// Algorithm identifies the compression algorithm in the header of the compressed data.
+type Algorithm byte
+
+const (
+	// None marks the data stored uncompressed although it starts with the header magic byte.
+	None Algorithm = iota
+	Gzip
+	Zstd
+	Snappy
+	LZ4
+)
+
+// headerMagic starts the header of the compressed data. Msgpack never produces this byte,
+// so the data written by MsgpackMarshaler before the compression was enabled is decoded as is.
+const headerMagic = 0xc1
+
+// HeaderSize is the size of the header prefixed to the compressed data.
+const HeaderSize = 2
+
+// DefaultMaxSize limits the decompressed size of the data unless the compressor sets its own limit,
+// so corrupted or crafted data cannot exhaust the memory.
+const DefaultMaxSize = 64 << 20
+
+// ErrUnknownAlgorithm indicates that the data was compressed by an unknown algorithm.
+var ErrUnknownAlgorithm = errors.New("unknown compression algorithm")
+
+// ErrTooLarge indicates that the decompressed data exceeds the max size of the compressor.
+var ErrTooLarge = errors.New("decompressed data is too large")
+
+// Compressor is the interface implemented by types that can compress and decompress bytes.
+type Compressor interface {
+	Algorithm() Algorithm
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+// Encode compresses the data that is not shorter than the threshold and prefixes it with the header.
+// The data that is shorter or does not get smaller is returned uncompressed.
+func Encode(c Compressor, data []byte, threshold int) ([]byte, error) {
+	if len(data) >= threshold {
+		compressed, err := c.Compress(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed)+HeaderSize < len(data) {
+			return withHeader(c.Algorithm(), compressed), nil
+		}
+	}
+
+	// uncompressed data starting with the magic byte must not be taken for the header
+	if len(data) > 0 && data[0] == headerMagic {
+		return withHeader(None, data), nil
+	}
+	return data, nil
+}
+
+// Decode decompresses the data produced by Encode. The data without the header is returned as is.
+// The data compressed by another built-in algorithm than the compressor's one is decompressed too.
+func Decode(c Compressor, data []byte) ([]byte, error) {
+	if len(data) < HeaderSize || data[0] != headerMagic {
+		return data, nil
+	}
+
+	alg := Algorithm(data[1])
+	data = data[HeaderSize:]
+	switch {
+	case alg == None:
+		return data, nil
+	case alg == c.Algorithm():
+		return c.Decompress(data)
+	}
+
+	builtin, ok := builtins[alg]
+	if !ok {
+		return nil, ErrUnknownAlgorithm
+	}
+	return builtin.Decompress(data)
+}
+
+var builtins = map[Algorithm]Compressor{
+	Gzip:   &GzipCompressor{},
+	Zstd:   &ZstdCompressor{},
+	Snappy: &SnappyCompressor{},
+	LZ4:    &LZ4Compressor{},
+}
+
+// maxSize returns the limit of the decompressed size, DefaultMaxSize if it is not set.
+func maxSize(n int) int {
+	if n <= 0 {
+		return DefaultMaxSize
+	}
+	return n
+}
+
+// readAll reads the decompressed data up to the max size.
+func readAll(r io.Reader, max int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > max {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func withHeader(alg Algorithm, data []byte) []byte {
+	b := make([]byte, HeaderSize+len(data))
+	b[0] = headerMagic
+	b[1] = byte(alg)
+	copy(b[HeaderSize:], data)
+	return b
+}
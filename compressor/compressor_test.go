@@ -0,0 +1,99 @@
+package compressor
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testData = bytes.Repeat([]byte("some value "), 100)
+
+func testCompressor(t *testing.T, c Compressor) {
+	compressed, err := c.Compress(testData)
+	assert.Nil(t, err)
+	assert.Less(t, len(compressed), len(testData))
+
+	data, err := c.Decompress(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, data, testData)
+
+	compressed, err = c.Compress(nil)
+	assert.Nil(t, err)
+
+	data, err = c.Decompress(compressed)
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+
+	_, err = c.Decompress([]byte("not compressed"))
+	assert.NotNil(t, err)
+}
+
+// testMaxSize checks that the limited compressor does not decompress the data larger than its max size.
+func testMaxSize(t *testing.T, c, limited Compressor) {
+	compressed, err := c.Compress(testData)
+	assert.Nil(t, err)
+	_, err = limited.Decompress(compressed)
+	assert.True(t, errors.Is(err, ErrTooLarge))
+
+	data, err := c.Decompress(compressed)
+	assert.Nil(t, err)
+	assert.Equal(t, data, testData)
+}
+
+func TestEncode(t *testing.T) {
+	c := &SnappyCompressor{}
+
+	// compressed
+	b, err := Encode(c, testData, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, b[:HeaderSize], []byte{headerMagic, byte(Snappy)})
+
+	data, err := Decode(c, b)
+	assert.Nil(t, err)
+	assert.Equal(t, data, testData)
+
+	// shorter than the threshold
+	b, err = Encode(c, []byte("some value"), 100)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("some value"))
+
+	data, err = Decode(c, b)
+	assert.Nil(t, err)
+	assert.Equal(t, data, []byte("some value"))
+
+	// does not get smaller
+	b, err = Encode(c, []byte("abc"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("abc"))
+
+	// starts with the magic byte
+	b, err = Encode(c, []byte{headerMagic, byte(Gzip)}, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{headerMagic, byte(None), headerMagic, byte(Gzip)})
+
+	data, err = Decode(c, b)
+	assert.Nil(t, err)
+	assert.Equal(t, data, []byte{headerMagic, byte(Gzip)})
+
+	b, err = Encode(c, nil, 100)
+	assert.Nil(t, err)
+	assert.Empty(t, b)
+}
+
+func TestDecode(t *testing.T) {
+	// compressed by another algorithm
+	b, err := Encode(&GzipCompressor{}, testData, 0)
+	assert.Nil(t, err)
+
+	data, err := Decode(&ZstdCompressor{}, b)
+	assert.Nil(t, err)
+	assert.Equal(t, data, testData)
+
+	_, err = Decode(&ZstdCompressor{}, []byte{headerMagic, 100, 1, 2, 3})
+	assert.True(t, errors.Is(err, ErrUnknownAlgorithm))
+
+	data, err = Decode(&ZstdCompressor{}, []byte{headerMagic})
+	assert.Nil(t, err)
+	assert.Equal(t, data, []byte{headerMagic})
+}
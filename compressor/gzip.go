@@ -0,0 +1,58 @@
+package compressor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+// GzipCompressor is a compressor that uses gzip. The zero Level means gzip.DefaultCompression,
+// the zero MaxSize means DefaultMaxSize. The Level must not be changed after the compressor was used.
+type GzipCompressor struct {
+	Level int
+	// MaxSize limits the decompressed size, Decompress returns ErrTooLarge if it is exceeded.
+	MaxSize int
+	writers sync.Pool
+}
+
+// Algorithm returns Gzip.
+func (c *GzipCompressor) Algorithm() Algorithm {
+	return Gzip
+}
+
+// Compress returns the gzip compressed data.
+func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var err error
+		if w, err = gzip.NewWriterLevel(&buf, level); err != nil {
+			return nil, err
+		}
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the data decompressed by gzip.
+func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readAll(r, maxSize(c.MaxSize))
+}
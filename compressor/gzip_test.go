@@ -0,0 +1,15 @@
+package compressor
+
+import (
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGzipCompressor(t *testing.T) {
+	c := &GzipCompressor{}
+	assert.Equal(t, c.Algorithm(), Gzip)
+	testCompressor(t, c)
+	testCompressor(t, &GzipCompressor{Level: gzip.BestSpeed})
+	testMaxSize(t, c, &GzipCompressor{MaxSize: len(testData) - 1})
+}
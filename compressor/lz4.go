@@ -0,0 +1,35 @@
+package compressor
+
+import (
+	"bytes"
+	"github.com/pierrec/lz4/v4"
+)
+
+// LZ4Compressor is a compressor that uses LZ4 frame format. The zero MaxSize means DefaultMaxSize.
+type LZ4Compressor struct {
+	// MaxSize limits the decompressed size, Decompress returns ErrTooLarge if it is exceeded.
+	MaxSize int
+}
+
+// Algorithm returns LZ4.
+func (c *LZ4Compressor) Algorithm() Algorithm {
+	return LZ4
+}
+
+// Compress returns the lz4 compressed data.
+func (c *LZ4Compressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := lz4.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the data decompressed by lz4.
+func (c *LZ4Compressor) Decompress(data []byte) ([]byte, error) {
+	return readAll(lz4.NewReader(bytes.NewReader(data)), maxSize(c.MaxSize))
+}
@@ -0,0 +1,13 @@
+package compressor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLZ4Compressor(t *testing.T) {
+	c := &LZ4Compressor{}
+	assert.Equal(t, c.Algorithm(), LZ4)
+	testCompressor(t, c)
+	testMaxSize(t, c, &LZ4Compressor{MaxSize: len(testData) - 1})
+}
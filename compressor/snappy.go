@@ -0,0 +1,34 @@
+package compressor
+
+import (
+	"github.com/klauspost/compress/snappy"
+)
+
+// SnappyCompressor is a compressor that uses Snappy block format. The zero MaxSize means DefaultMaxSize.
+type SnappyCompressor struct {
+	// MaxSize limits the decompressed size, Decompress returns ErrTooLarge if it is exceeded.
+	MaxSize int
+}
+
+// Algorithm returns Snappy.
+func (c *SnappyCompressor) Algorithm() Algorithm {
+	return Snappy
+}
+
+// Compress returns the snappy compressed data.
+func (c *SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+// Decompress returns the data decompressed by snappy.
+func (c *SnappyCompressor) Decompress(data []byte) ([]byte, error) {
+	// the block starts with the decoded length, which is checked before the allocation
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSize(c.MaxSize) {
+		return nil, ErrTooLarge
+	}
+	return snappy.Decode(nil, data)
+}
@@ -0,0 +1,13 @@
+package compressor
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSnappyCompressor(t *testing.T) {
+	c := &SnappyCompressor{}
+	assert.Equal(t, c.Algorithm(), Snappy)
+	testCompressor(t, c)
+	testMaxSize(t, c, &SnappyCompressor{MaxSize: len(testData) - 1})
+}
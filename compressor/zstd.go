@@ -0,0 +1,61 @@
+package compressor
+
+import (
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"sync"
+)
+
+// ZstdCompressor is a compressor that uses Zstandard. The zero Level means zstd.SpeedDefault,
+// the zero MaxSize means DefaultMaxSize. The Level and MaxSize must not be changed after the compressor was used.
+type ZstdCompressor struct {
+	Level zstd.EncoderLevel
+	// MaxSize limits the decompressed size, Decompress returns ErrTooLarge if it is exceeded.
+	MaxSize int
+
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+// Algorithm returns Zstd.
+func (c *ZstdCompressor) Algorithm() Algorithm {
+	return Zstd
+}
+
+// Compress returns the zstd compressed data.
+func (c *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+// Decompress returns the data decompressed by zstd.
+func (c *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	data, err := c.decoder.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrTooLarge
+	}
+	return data, err
+}
+
+// init creates the encoder and decoder once, they are safe for concurrent use by EncodeAll and DecodeAll.
+func (c *ZstdCompressor) init() error {
+	c.once.Do(func() {
+		level := c.Level
+		if level == 0 {
+			level = zstd.SpeedDefault
+		}
+		c.encoder, c.err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+		if c.err != nil {
+			return
+		}
+		c.decoder, c.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize(c.MaxSize))))
+	})
+	return c.err
+}
@@ -0,0 +1,15 @@
+package compressor
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	c := &ZstdCompressor{}
+	assert.Equal(t, c.Algorithm(), Zstd)
+	testCompressor(t, c)
+	testCompressor(t, &ZstdCompressor{Level: zstd.SpeedBestCompression})
+	testMaxSize(t, c, &ZstdCompressor{MaxSize: len(testData) - 1})
+}
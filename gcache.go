@@ -3,27 +3,60 @@ package gcache
 import (
 	"context"
 	"errors"
-	"github.com/amerkurev/gcache/internal/hasher"
-	"github.com/amerkurev/gcache/internal/marshaler"
-	"github.com/amerkurev/gcache/internal/stats"
+	"github.com/amerkurev/gcache/compressor"
+	"github.com/amerkurev/gcache/hasher"
+	"github.com/amerkurev/gcache/internal/singleflight"
+	"github.com/amerkurev/gcache/marshaler"
+	"github.com/amerkurev/gcache/stats"
 	"github.com/amerkurev/gcache/store"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Cache represents the interface for all caches
 type Cache[KeyType comparable, ValueType any] interface {
 	Get(KeyType) (ValueType, error)
 	Set(KeyType, ValueType) error
+	SetWithTTL(KeyType, ValueType, time.Duration) error
 	Delete(KeyType) error
 	Clear() error
 
 	GetWithContext(context.Context, KeyType) (ValueType, error)
 	SetWithContext(context.Context, KeyType, ValueType) error
+	SetWithOptions(context.Context, KeyType, ValueType, ...SetOption) error
 	DeleteWithContext(context.Context, KeyType) error
 	ClearWithContext(context.Context) error
 
+	GetOrLoad(context.Context, KeyType, LoaderFunc[KeyType, ValueType], ...SetOption) (ValueType, error)
+
+	GetMany(context.Context, []KeyType) []Result[KeyType, ValueType]
+
+	// GetResult reads the value like Get, and reports whether it is stale, see WithStale.
+	GetResult(context.Context, KeyType) Result[KeyType, ValueType]
+	// SetLoader registers the loader that refreshes the stale values read by Get, GetResult and GetMany.
+	SetLoader(LoaderFunc[KeyType, ValueType])
+	SetMany(context.Context, map[KeyType]ValueType, ...SetOption) map[KeyType]error
+	DeleteMany(context.Context, []KeyType) map[KeyType]error
+
 	UseStats()
 	ResetStats()
 	Stats() (stats.Stats, bool)
+
+	// OnHit registers a hook called for every key found in the cache.
+	OnHit(func(Event[KeyType, ValueType]))
+	// OnMiss registers a hook called for every key not found in the cache.
+	OnMiss(func(Event[KeyType, ValueType]))
+	// OnSet registers a hook called for every value written to the store.
+	OnSet(func(Event[KeyType, ValueType]))
+	// OnDelete registers a hook called for every deleted key.
+	OnDelete(func(Event[KeyType, ValueType]))
+	// OnEvict registers a hook called for every entry evicted by the store, see store.EventStore.
+	OnEvict(func(Event[KeyType, ValueType]))
+	// OnExpire registers a hook called for every expired entry removed by the store, see store.EventStore.
+	OnExpire(func(Event[KeyType, ValueType]))
+	// OnError registers a hook called for every failed key or operation.
+	OnError(func(Event[KeyType, ValueType]))
 }
 
 type cache[KeyType comparable, ValueType any] struct {
@@ -31,8 +64,24 @@ type cache[KeyType comparable, ValueType any] struct {
 	marshaler.Marshaler
 	store.Store
 
+	compressor        compressor.Compressor
+	compressThreshold int
+
+	staleGrace time.Duration
+	loader     atomic.Value // LoaderFunc[KeyType, ValueType]
+	refreshing sync.Map     // store keys of the stale values being refreshed
+	// store keys of the failed refreshes and their times, the keys are not refreshed again for refreshRetry
+	refreshFailed sync.Map
+	refreshRetry  time.Duration
+
+	observer Observer
+	latency  bool    // the stats collect the latency of operations
+	handler  Handler // nil if there are no middlewares
+
+	loads singleflight.Group[ValueType]
+	hooks hooks[KeyType, ValueType]
+
 	*stats.SyncStats
-	useStats bool
 }
 
 func (c *cache[K, V]) Get(key K) (V, error) {
@@ -43,6 +92,10 @@ func (c *cache[K, V]) Set(key K, value V) error {
 	return c.SetWithContext(context.Background(), key, value)
 }
 
+func (c *cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) error {
+	return c.SetWithOptions(context.Background(), key, value, WithTTL(ttl))
+}
+
 func (c *cache[K, V]) Delete(key K) error {
 	return c.DeleteWithContext(context.Background(), key)
 }
@@ -52,99 +105,399 @@ func (c *cache[K, V]) Clear() error {
 }
 
 func (c *cache[K, V]) GetWithContext(ctx context.Context, key K) (value V, err error) {
+	var obs Observation
+	ctx = c.observe(ctx, OpGet, &obs)
+	defer c.done(&obs)
+
 	k, err := c.Hash(key)
+	obs.Hash += obs.lap()
 	if err != nil {
-		if c.useStats {
+		if c.Enabled() {
 			c.ErrRead()
 		}
+		obs.fail(err)
+		c.emitError(OpGet, key, "", err)
 		return
 	}
 
-	b, err := c.Store.Get(ctx, k)
+	value, h, err := c.get(ctx, key, k, &obs)
+	c.revalidate(key, k, h, err, c.registeredLoader())
+	return value, err
+}
+
+// get reads the value from the store and accounts the read in the observation.
+func (c *cache[K, V]) get(ctx context.Context, key K, k string, obs *Observation) (V, staleHeader, error) {
+	b, err := c.storeGet(ctx, key, k)
+	obs.Store += obs.lap()
+
+	value, h, err := c.decode(b, err, obs)
+	obs.read(len(b), err)
+	c.emitRead(obs.Op, key, k, value, err)
+	return value, h, err
+}
+
+// decode unmarshals the data read from the store and counts the read operation.
+func (c *cache[K, V]) decode(b []byte, err error, obs *Observation) (value V, h staleHeader, _ error) {
 	if err != nil {
-		if c.useStats {
+		if c.Enabled() {
 			if errors.Is(err, ErrNotFound) {
-				c.IncRead(false, 0)
+				c.IncRead(false, 0, 0)
 			} else {
 				c.ErrRead()
 			}
 		}
-		return
+		return value, h, err
 	}
 
-	err = c.Unmarshal(b, &value)
-	if c.useStats {
+	data, h := c.splitStaleHeader(b)
+	raw, err := c.decompress(data)
+	if err == nil {
+		err = c.Unmarshal(raw, &value)
+	}
+	obs.Marshal += obs.lap()
+	if c.Enabled() {
 		if err != nil {
 			c.ErrRead()
 		} else {
-			c.IncRead(true, len(b))
+			c.IncRead(true, len(b), len(raw))
 		}
 	}
-	return
+	return value, h, err
+}
+
+func (c *cache[K, V]) decompress(b []byte) ([]byte, error) {
+	if c.compressor == nil {
+		return b, nil
+	}
+	return compressor.Decode(c.compressor, b)
 }
 
 func (c *cache[K, V]) SetWithContext(ctx context.Context, key K, value V) error {
+	return c.SetWithOptions(ctx, key, value)
+}
+
+func (c *cache[K, V]) SetWithOptions(ctx context.Context, key K, value V, opts ...SetOption) error {
+	var obs Observation
+	ctx = c.observe(ctx, OpSet, &obs)
+	defer c.done(&obs)
+
+	o := newSetOptions(opts)
+
 	k, err := c.Hash(key)
+	obs.Hash += obs.lap()
 	if err != nil {
-		if c.useStats {
+		if c.Enabled() {
 			c.ErrWrite()
 		}
+		obs.fail(err)
+		c.emitError(OpSet, key, "", err)
 		return err
 	}
 
-	v, err := c.Marshal(value)
+	return c.set(ctx, key, k, value, o, &obs)
+}
+
+// set writes the value into the store and accounts the write in the observation.
+func (c *cache[K, V]) set(ctx context.Context, key K, k string, value V, o setOptions, obs *Observation) error {
+	v, raw, err := c.encode(value, obs)
 	if err != nil {
-		if c.useStats {
-			c.ErrWrite()
-		}
+		obs.fail(err)
+		c.emitError(obs.Op, key, k, err)
 		return err
 	}
 
-	err = c.Store.Set(ctx, k, v)
-	if c.useStats {
+	v, ttl := c.withStaleHeader(v, o.ttl)
+	err = c.storeSet(ctx, key, k, v, ttl)
+	obs.Store += obs.lap()
+
+	c.countWrite(len(v), raw, err)
+	obs.write(len(v), err)
+	c.emitWrite(obs.Op, key, k, value, err)
+	return err
+}
+
+// encode marshals and compresses the value to be written into the store and counts the failure.
+// It returns the size of the marshaled value before compression.
+func (c *cache[K, V]) encode(value V, obs *Observation) ([]byte, int, error) {
+	v, err := c.Marshal(value)
+	raw := len(v)
+	if err == nil && c.compressor != nil {
+		v, err = compressor.Encode(c.compressor, v, c.compressThreshold)
+	}
+	obs.Marshal += obs.lap()
+	if err != nil && c.Enabled() {
+		c.ErrWrite()
+	}
+	return v, raw, err
+}
+
+func (c *cache[K, V]) countWrite(n, raw int, err error) {
+	if c.Enabled() {
 		if err != nil {
 			c.ErrWrite()
 		} else {
-			c.IncWrite(len(v))
+			c.IncWrite(n, raw)
 		}
 	}
-	return err
 }
 
 func (c *cache[K, V]) DeleteWithContext(ctx context.Context, key K) error {
+	var obs Observation
+	ctx = c.observe(ctx, OpDelete, &obs)
+	defer c.done(&obs)
+
 	k, err := c.Hash(key)
+	obs.Hash += obs.lap()
 	if err != nil {
-		if c.useStats {
+		if c.Enabled() {
 			c.ErrDelete()
 		}
+		obs.fail(err)
+		c.emitError(OpDelete, key, "", err)
 		return err
 	}
 
-	err = c.Store.Delete(ctx, k)
-	if c.useStats {
+	err = c.storeDelete(ctx, key, k)
+	obs.Store += obs.lap()
+	c.countDelete(err)
+	obs.write(0, err)
+	c.emitDelete(OpDelete, key, k, err)
+	return err
+}
+
+func (c *cache[K, V]) countDelete(err error) {
+	if c.Enabled() {
 		if err != nil {
 			c.ErrDelete()
 		} else {
 			c.IncDelete()
 		}
 	}
-	return err
 }
 
 func (c *cache[K, V]) ClearWithContext(ctx context.Context) error {
-	err := c.Store.Clear(ctx)
-	if c.useStats {
+	var obs Observation
+	ctx = c.observe(ctx, OpClear, &obs)
+	defer c.done(&obs)
+
+	err := c.storeClear(ctx)
+	obs.Store += obs.lap()
+	obs.write(0, err)
+	if c.Enabled() {
 		if err != nil {
 			c.ErrClear()
 		} else {
 			c.IncClear()
 		}
 	}
+	if err != nil {
+		c.emit(hookError, Event[K, V]{Op: OpClear, Err: err})
+	}
 	return err
 }
 
+func (c *cache[K, V]) GetOrLoad(ctx context.Context, key K, loader LoaderFunc[K, V], opts ...SetOption) (V, error) {
+	var obs Observation
+	ctx = c.observe(ctx, OpGetOrLoad, &obs)
+	defer c.done(&obs)
+
+	k, err := c.Hash(key)
+	obs.Hash += obs.lap()
+	if err != nil {
+		if c.Enabled() {
+			c.ErrRead()
+		}
+		obs.fail(err)
+		c.emitError(OpGetOrLoad, key, "", err)
+		var zero V
+		return zero, err
+	}
+
+	value, h, err := c.get(ctx, key, k, &obs)
+	if !errors.Is(err, ErrNotFound) {
+		c.revalidate(key, k, h, err, loader)
+		return value, err
+	}
+
+	// the load is shared by the callers, so it is not bound to the context of the one that started it,
+	// and every caller stops waiting when its own context is done
+	timed := obs.timed
+	var write Observation
+	ch := c.loads.DoChan(k, func() (V, error) {
+		ctx := detachedContext{ctx}
+		v, err := loader(ctx, key)
+		if err != nil {
+			c.emitError(OpGetOrLoad, key, k, err)
+			return v, err
+		}
+		// the loaded value is not accounted as read, only the time of writing it
+		write.Op = OpGetOrLoad
+		if timed {
+			write.startTiming(time.Now())
+		}
+		err = c.set(ctx, key, k, v, newSetOptions(opts), &write)
+		return v, err
+	})
+	select {
+	case r := <-ch:
+		value, err = r.Val, r.Err
+		obs.Marshal += write.Marshal
+		obs.Store += write.Store
+	case <-ctx.Done():
+		var zero V
+		value, err = zero, ctx.Err()
+	}
+	if err != nil {
+		obs.fail(err)
+	}
+	return value, err
+}
+
+// detachedContext keeps the values of the parent context but not its deadline and cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c *cache[K, V]) GetMany(ctx context.Context, keys []K) []Result[K, V] {
+	var obs Observation
+	ctx = c.observe(ctx, OpGetMany, &obs)
+	defer c.done(&obs)
+
+	results := make([]Result[K, V], len(keys))
+	hashed := make([]string, 0, len(keys))
+	hashedKeys := make([]K, 0, len(keys))
+	indexes := make([]int, 0, len(keys))
+
+	for i, key := range keys {
+		results[i].Key = key
+		k, err := c.Hash(key)
+		obs.Hash += obs.lap()
+		if err != nil {
+			if c.Enabled() {
+				c.ErrRead()
+			}
+			results[i].Err = err
+			obs.fail(err)
+			c.emitError(OpGetMany, key, "", err)
+			continue
+		}
+		hashed = append(hashed, k)
+		hashedKeys = append(hashedKeys, key)
+		indexes = append(indexes, i)
+	}
+
+	data, errs := c.storeGetMany(ctx, hashedKeys, hashed)
+	obs.Store += obs.lap()
+
+	loader := c.registeredLoader()
+	for j, i := range indexes {
+		r := &results[i]
+		var h staleHeader
+		r.Value, h, r.Err = c.decode(data[j], errs[j], &obs)
+		obs.read(len(data[j]), r.Err)
+		c.emitRead(OpGetMany, r.Key, hashed[j], r.Value, r.Err)
+		r.Stale = c.revalidate(r.Key, hashed[j], h, r.Err, loader)
+	}
+	return results
+}
+
+func (c *cache[K, V]) SetMany(ctx context.Context, items map[K]V, opts ...SetOption) map[K]error {
+	var obs Observation
+	ctx = c.observe(ctx, OpSetMany, &obs)
+	defer c.done(&obs)
+
+	o := newSetOptions(opts)
+	failed := make(map[K]error)
+	entries := make([]store.Entry, 0, len(items))
+	keys := make([]K, 0, len(items))
+	raws := make([]int, 0, len(items))
+
+	for key, value := range items {
+		k, err := c.Hash(key)
+		obs.Hash += obs.lap()
+		if err != nil {
+			if c.Enabled() {
+				c.ErrWrite()
+			}
+			failed[key] = err
+			obs.fail(err)
+			c.emitError(OpSetMany, key, "", err)
+			continue
+		}
+
+		v, raw, err := c.encode(value, &obs)
+		if err != nil {
+			failed[key] = err
+			obs.fail(err)
+			c.emitError(OpSetMany, key, k, err)
+			continue
+		}
+
+		v, ttl := c.withStaleHeader(v, o.ttl)
+		entries = append(entries, store.Entry{Key: k, Data: v, TTL: ttl})
+		keys = append(keys, key)
+		raws = append(raws, raw)
+	}
+
+	errs := c.storeSetMany(ctx, keys, entries)
+	obs.Store += obs.lap()
+
+	for i, err := range errs {
+		c.countWrite(len(entries[i].Data), raws[i], err)
+		obs.write(len(entries[i].Data), err)
+		c.emitWrite(OpSetMany, keys[i], entries[i].Key, items[keys[i]], err)
+		if err != nil {
+			failed[keys[i]] = err
+		}
+	}
+	return nilIfEmpty(failed)
+}
+
+func (c *cache[K, V]) DeleteMany(ctx context.Context, keys []K) map[K]error {
+	var obs Observation
+	ctx = c.observe(ctx, OpDeleteMany, &obs)
+	defer c.done(&obs)
+
+	failed := make(map[K]error)
+	hashed := make([]string, 0, len(keys))
+	hashedKeys := make([]K, 0, len(keys))
+
+	for _, key := range keys {
+		k, err := c.Hash(key)
+		obs.Hash += obs.lap()
+		if err != nil {
+			if c.Enabled() {
+				c.ErrDelete()
+			}
+			failed[key] = err
+			obs.fail(err)
+			c.emitError(OpDeleteMany, key, "", err)
+			continue
+		}
+		hashed = append(hashed, k)
+		hashedKeys = append(hashedKeys, key)
+	}
+
+	errs := c.storeDeleteMany(ctx, hashedKeys, hashed)
+	obs.Store += obs.lap()
+
+	for i, err := range errs {
+		c.countDelete(err)
+		obs.write(0, err)
+		c.emitDelete(OpDeleteMany, hashedKeys[i], hashed[i], err)
+		if err != nil {
+			failed[hashedKeys[i]] = err
+		}
+	}
+	return nilIfEmpty(failed)
+}
+
 func (c *cache[K, V]) UseStats() {
-	c.useStats = true
+	c.Enable()
 }
 
 func (c *cache[K, V]) ResetStats() {
@@ -152,18 +505,70 @@ func (c *cache[K, V]) ResetStats() {
 }
 
 func (c *cache[K, V]) Stats() (stats.Stats, bool) {
-	return c.SyncStats.Snapshot(), c.useStats
+	return c.SyncStats.Snapshot(), c.Enabled()
 }
 
 // New creates a new instance of cache object.
-func New[K comparable, V any](s store.Store) Cache[K, V] {
-	return &cache[K, V]{
-		Hasher:    &hasher.MsgpackHasher{},
-		Marshaler: &marshaler.MsgpackMarshaler{},
+func New[K comparable, V any](s store.Store, opts ...Option) Cache[K, V] {
+	o := newOptions(opts)
+	if o.namespace != "" {
+		s = store.Namespace(s, o.namespace)
+	}
+	c := &cache[K, V]{
+		Hasher:    o.hasher,
+		Marshaler: o.marshaler,
 		Store:     s,
+
+		compressor:        o.compressor,
+		compressThreshold: o.compressThreshold,
+
+		staleGrace:   o.staleGrace,
+		refreshRetry: staleRefreshRetry,
+
+		observer: o.observer(),
+		latency:  o.latency,
+
 		SyncStats: &stats.SyncStats{},
 	}
+	if o.useStats {
+		c.Enable()
+	}
+	if len(o.middlewares) > 0 {
+		c.handler = chain(c.handle, o.middlewares)
+	}
+	// the stores that do not report evictions and expirations never fire the hooks
+	_ = store.Subscribe(s, c.storeEvent)
+	return c
+}
+
+// Result is the outcome of reading a single key by GetMany or GetResult. A missing key has ErrNotFound error.
+type Result[K comparable, V any] struct {
+	Key   K
+	Value V
+	Err   error
+	// Stale is set for the values past their time-to-live served during the grace period, see WithStale.
+	Stale bool
 }
 
+// Found reports whether the key was found in the cache.
+func (r Result[K, V]) Found() bool {
+	return r.Err == nil
+}
+
+func nilIfEmpty[K comparable](m map[K]error) map[K]error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// LoaderFunc loads the value of the key missing in the cache. Concurrent misses of the same key
+// are coalesced into a single call, whose value or error is returned to every caller. The call gets
+// the values of the context of the caller that started it, but not its deadline and cancellation.
+type LoaderFunc[K comparable, V any] func(context.Context, K) (V, error)
+
 // ErrNotFound indicates that key not found in the cache.
 var ErrNotFound = store.ErrNotFound
+
+// ErrTTLNotSupported indicates that the cache store cannot expire entries.
+var ErrTTLNotSupported = store.ErrTTLNotSupported
@@ -4,14 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/allegro/bigcache/v3"
-	"github.com/amerkurev/gcache/internal/hasher"
-	"github.com/amerkurev/gcache/internal/marshaler"
+	"github.com/amerkurev/gcache/compressor"
+	"github.com/amerkurev/gcache/hasher"
+	"github.com/amerkurev/gcache/marshaler"
+	"github.com/amerkurev/gcache/stats"
 	"github.com/amerkurev/gcache/store"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -294,7 +300,7 @@ func TestRedisCache_Concurrency(t *testing.T) {
 }
 
 func TestSQLiteCache_Concurrency(t *testing.T) {
-	db, err := sql.Open("sqlite3", "test.db")
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
 	assert.Nil(t, err)
 	t.Cleanup(func() {
 		assert.Nil(t, db.Close())
@@ -512,3 +518,514 @@ func TestCacheStats_Error(t *testing.T) {
 	assert.Equal(t, s.WriteCount, 0)
 	assert.Equal(t, s.ErrWriteCount, 1)
 }
+
+func TestMapCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := New[string, string](store.MapStore(0))
+
+	err := c.SetWithTTL("a", "some value", 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = c.SetWithOptions(ctx, "b", "another value", WithTTL(time.Minute))
+	assert.Nil(t, err)
+	err = c.SetWithOptions(ctx, "c", "never expires")
+	assert.Nil(t, err)
+
+	v, err := c.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, v, "some value")
+
+	time.Sleep(20 * time.Millisecond)
+
+	v, err = c.Get("a")
+	assert.Equal(t, v, "")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	v, err = c.Get("b")
+	assert.Nil(t, err)
+	assert.Equal(t, v, "another value")
+
+	v, err = c.Get("c")
+	assert.Nil(t, err)
+	assert.Equal(t, v, "never expires")
+}
+
+type noTTLStore struct {
+	store.Store
+}
+
+func TestCache_TTLNotSupported(t *testing.T) {
+	c := New[string, string](noTTLStore{store.MapStore(0)})
+
+	err := c.SetWithTTL("a", "some value", time.Minute)
+	assert.True(t, errors.Is(err, ErrTTLNotSupported))
+
+	// zero ttl does not require the store support
+	err = c.SetWithTTL("a", "some value", 0)
+	assert.Nil(t, err)
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0))
+	c.UseStats()
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func(_ context.Context, key int) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return fmt.Sprintf("value %d", key), nil
+	}
+
+	goroutines := 10
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(ctx, 1, loader)
+			assert.Nil(t, err)
+			assert.Equal(t, v, "value 1")
+		}()
+	}
+
+	// let the goroutines join the in-flight load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// the loaded value is stored
+	v, err := c.GetOrLoad(ctx, 1, loader)
+	assert.Nil(t, err)
+	assert.Equal(t, v, "value 1")
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	s, ok := c.Stats()
+	assert.True(t, ok)
+	assert.Equal(t, s.WriteCount, 1)
+}
+
+func TestCache_GetOrLoadError(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0))
+
+	someErr := errors.New("some error")
+	release := make(chan struct{})
+	loader := func(_ context.Context, key int) (string, error) {
+		<-release
+		return "", someErr
+	}
+
+	goroutines := 10
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetOrLoad(ctx, 1, loader)
+			assert.True(t, errors.Is(err, someErr))
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// a failed load is not stored
+	_, err := c.Get(1)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestCache_GetOrLoadContext(t *testing.T) {
+	type ctxKey struct{}
+	c := New[int, string](store.MapStore(0))
+
+	release := make(chan struct{})
+	loaded := make(chan error, 1)
+	loader := func(ctx context.Context, key int) (string, error) {
+		<-release
+		assert.Equal(t, ctx.Value(ctxKey{}), "request")
+		loaded <- ctx.Err()
+		return fmt.Sprintf("value %d", key), nil
+	}
+
+	// the caller that started the load stops waiting when its context is canceled
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "request"))
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		_, err := c.GetOrLoad(ctx, 1, loader)
+		assert.True(t, errors.Is(err, context.Canceled))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-started
+
+	// the load goes on for the other callers
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := c.GetOrLoad(context.Background(), 1, loader)
+		assert.Nil(t, err)
+		assert.Equal(t, v, "value 1")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+	assert.Nil(t, <-loaded)
+
+	v, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, v, "value 1")
+}
+
+func TestCache_GetOrLoadTTL(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, int](store.MapStore(0))
+
+	loader := func(_ context.Context, key int) (int, error) {
+		return key * key, nil
+	}
+
+	v, err := c.GetOrLoad(ctx, 10, loader, WithTTL(10*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, v, 100)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.Get(10)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestCache_Batch(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0))
+	c.UseStats()
+
+	failed := c.SetMany(ctx, map[int]string{1: "a", 2: "b", 3: "c"}, WithTTL(time.Minute))
+	assert.Nil(t, failed)
+
+	results := c.GetMany(ctx, []int{1, 2, 4})
+	assert.Len(t, results, 3)
+	assert.Equal(t, results[0], Result[int, string]{Key: 1, Value: "a"})
+	assert.Equal(t, results[1], Result[int, string]{Key: 2, Value: "b"})
+	assert.Equal(t, results[2].Key, 4)
+	assert.False(t, results[2].Found())
+	assert.True(t, errors.Is(results[2].Err, ErrNotFound))
+
+	failed = c.DeleteMany(ctx, []int{1, 4})
+	assert.Nil(t, failed)
+
+	results = c.GetMany(ctx, []int{1, 2})
+	assert.False(t, results[0].Found())
+	assert.True(t, results[1].Found())
+
+	s, ok := c.Stats()
+	assert.True(t, ok)
+	assert.Equal(t, s.WriteCount, 3)
+	assert.Equal(t, s.ReadCount, 5)
+	assert.Equal(t, s.Hits, 3)
+	assert.Equal(t, s.Miss, 2)
+	assert.Equal(t, s.DeleteCount, 2)
+}
+
+func TestCache_BatchErrors(t *testing.T) {
+	var hashError *hasher.Error
+	var marshalError *marshaler.MarshalError
+	ctx := context.Background()
+
+	c := New[int, complex128](store.MapStore(0))
+	c.UseStats()
+
+	failed := c.SetMany(ctx, map[int]complex128{1: complex(1, 2)})
+	assert.Len(t, failed, 1)
+	assert.True(t, errors.As(failed[1], &marshalError))
+
+	s, ok := c.Stats()
+	assert.True(t, ok)
+	assert.Equal(t, s.WriteCount, 0)
+	assert.Equal(t, s.ErrWriteCount, 1)
+
+	u := New[complex128, int](store.MapStore(0))
+	u.UseStats()
+
+	hashFailed := u.SetMany(ctx, map[complex128]int{complex(1, 2): 1})
+	assert.True(t, errors.As(hashFailed[complex(1, 2)], &hashError))
+
+	results := u.GetMany(ctx, []complex128{complex(1, 2)})
+	assert.True(t, errors.As(results[0].Err, &hashError))
+
+	hashFailed = u.DeleteMany(ctx, []complex128{complex(1, 2)})
+	assert.True(t, errors.As(hashFailed[complex(1, 2)], &hashError))
+
+	s, ok = u.Stats()
+	assert.True(t, ok)
+	assert.Equal(t, s.ErrWriteCount, 1)
+	assert.Equal(t, s.ErrReadCount, 1)
+	assert.Equal(t, s.ErrDeleteCount, 1)
+}
+
+type upperHasher struct{}
+
+func (upperHasher) Hash(v any) (string, error) {
+	return strings.ToUpper(fmt.Sprint(v)), nil
+}
+
+type stringMarshaler struct{}
+
+func (stringMarshaler) Marshal(v any) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func (stringMarshaler) Unmarshal(b []byte, v any) error {
+	*v.(*string) = string(b)
+	return nil
+}
+
+func TestCache_Options(t *testing.T) {
+	ctx := context.Background()
+	s := store.MapStore(0)
+	c := New[string, string](s, WithHasher(upperHasher{}), WithMarshaler(stringMarshaler{}), WithStats())
+
+	err := c.Set("key", "some value")
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "KEY")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("some value"))
+
+	v, err := c.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, v, "some value")
+
+	st, ok := c.Stats()
+	assert.True(t, ok)
+	assert.Equal(t, st.WriteCount, 1)
+	assert.Equal(t, st.Hits, 1)
+}
+
+func TestCache_Namespace(t *testing.T) {
+	s := store.MapStore(0)
+	names := New[int, string](s, WithNamespace("names"))
+	users := New[int, *user](s, WithNamespace("users"))
+
+	err := names.Set(1, "John")
+	assert.Nil(t, err)
+	err = users.Set(1, &user{"Mary"})
+	assert.Nil(t, err)
+
+	name, err := names.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, name, "John")
+
+	u, err := users.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, u, &user{"Mary"})
+
+	err = names.Delete(1)
+	assert.Nil(t, err)
+
+	u, err = users.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, u, &user{"Mary"})
+
+	// clear removes the entries of the namespace only
+	err = names.Set(2, "Mary")
+	assert.Nil(t, err)
+	err = users.Clear()
+	assert.Nil(t, err)
+
+	_, err = users.Get(1)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	name, err = names.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, name, "Mary")
+}
+
+func TestCache_Marshalers(t *testing.T) {
+	marshalers := []marshaler.Marshaler{
+		&marshaler.MsgpackMarshaler{},
+		&marshaler.JSONMarshaler{},
+		&marshaler.GobMarshaler{},
+		&marshaler.CBORMarshaler{},
+	}
+
+	for _, m := range marshalers {
+		c := New[int, *user](store.MapStore(0), WithMarshaler(m))
+		err := c.Set(1, &user{"John"})
+		assert.Nil(t, err)
+
+		u, err := c.Get(1)
+		assert.Nil(t, err)
+		assert.Equal(t, u, &user{"John"})
+	}
+
+	c := New[int, []byte](store.MapStore(0), WithMarshaler(&marshaler.RawMarshaler{}))
+	err := c.Set(1, []byte("some value"))
+	assert.Nil(t, err)
+
+	b, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("some value"))
+}
+
+func TestCache_Compression(t *testing.T) {
+	ctx := context.Background()
+	s := store.MapStore(0)
+	value := strings.Repeat("some value ", 100)
+
+	// the entry written before the compression was enabled
+	legacy := New[int, string](s)
+	err := legacy.Set(1, value)
+	assert.Nil(t, err)
+
+	c := New[int, string](s, WithCompression(&compressor.ZstdCompressor{}, 100), WithStats())
+	v, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, v, value)
+
+	err = c.Set(2, value)
+	assert.Nil(t, err)
+	err = c.Set(3, "short value")
+	assert.Nil(t, err)
+
+	k, _ := c.(*cache[int, string]).Hash(2)
+	b, err := s.Get(ctx, k)
+	assert.Nil(t, err)
+	assert.Equal(t, b[:compressor.HeaderSize], []byte{0xc1, byte(compressor.Zstd)})
+
+	for _, key := range []int{2, 3} {
+		v, err = c.Get(key)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, v, "short value")
+
+	// another algorithm reads the entries
+	other := New[int, string](s, WithCompression(&compressor.GzipCompressor{}, 100))
+	v, err = other.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, v, value)
+
+	st, _ := c.Stats()
+	assert.Equal(t, st.RawWriteBytes, len(value)+3+len("short value")+1)
+	assert.Less(t, st.WriteBytes, st.RawWriteBytes)
+	assert.Equal(t, st.RawReadBytes, 2*(len(value)+3)+len("short value")+1)
+	assert.Less(t, st.ReadBytes, st.RawReadBytes)
+}
+
+func TestCacheStats_Latency(t *testing.T) {
+	// the latency is not collected by default
+	c := New[int, string](store.MapStore(0), WithStats())
+	assert.Nil(t, c.Set(1, "some value"))
+	s, _ := c.Stats()
+	assert.Equal(t, s.WriteCount, 1)
+	assert.Equal(t, s.Latency, stats.LatencyStats{})
+
+	c = New[int, string](store.MapStore(0), WithStats(), WithLatency())
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, c.Set(i, "some value"))
+	}
+	for i := 0; i < 15; i++ {
+		_, _ = c.Get(i)
+	}
+	assert.Nil(t, c.Delete(1))
+	assert.Nil(t, c.Clear())
+	_ = c.GetMany(context.Background(), []int{1, 2}) // batches are not recorded
+
+	s, _ = c.Stats()
+	l := s.Latency
+	assert.Equal(t, l.Set.Total.Count, 10)
+	assert.Equal(t, l.Set.Hash.Count, 10)
+	assert.Equal(t, l.Set.Marshal.Count, 10)
+	assert.Equal(t, l.Set.Store.Count, 10)
+	assert.Equal(t, l.Get.Total.Count, 15)
+	assert.Equal(t, l.GetHit.Total.Count, 10)
+	assert.Equal(t, l.GetMiss.Total.Count, 5)
+	assert.Equal(t, l.Delete.Total.Count, 1)
+	assert.Equal(t, l.Clear.Total.Count, 1)
+	assert.LessOrEqual(t, l.Get.Total.Min, l.Get.Total.P50)
+	assert.LessOrEqual(t, l.Get.Total.P50, l.Get.Total.P99)
+	assert.LessOrEqual(t, l.Get.Total.P99, l.Get.Total.Max)
+	assert.LessOrEqual(t, l.GetHit.Store.Max, l.GetHit.Total.Max)
+
+	c.ResetStats()
+	s, _ = c.Stats()
+	assert.Equal(t, s.Latency.Get.Total.Count, 0)
+}
+
+func TestCacheStats_UseStatsConcurrently(t *testing.T) {
+	c := New[int, int](store.MapStore(0), WithLatency())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				assert.Nil(t, c.Set(k, k))
+				_, _ = c.Get(k)
+			}
+		}()
+	}
+	c.UseStats()
+	wg.Wait()
+
+	s, ok := c.Stats()
+	assert.True(t, ok)
+	assert.LessOrEqual(t, s.WriteCount, 1000)
+	assert.Equal(t, s.Latency.Set.Total.Count, s.WriteCount)
+}
+
+func TestCacheStats_Delta(t *testing.T) {
+	c := New[int, string](store.MapStore(0), WithStats())
+	assert.Nil(t, c.Set(1, "one"))
+	_, _ = c.Get(1)
+
+	var prev stats.Stats
+	prev, _ = c.Stats()
+	_, _ = c.Get(1)
+	_, _ = c.Get(2)
+	_, _ = c.Get(3)
+	_, _ = c.Get(4)
+	cur, _ := c.Stats()
+
+	assert.Equal(t, cur.HitRatio(), 0.4)
+	d := cur.Sub(prev)
+	assert.Equal(t, d.Hits, 1)
+	assert.Equal(t, d.Miss, 3)
+	assert.Equal(t, d.HitRatio(), 0.25)
+	assert.Equal(t, d.WriteCount, 0)
+	assert.Equal(t, d.AvgValueSize(), float64(d.ReadBytes)) // a single hit
+	assert.GreaterOrEqual(t, d.Interval, time.Duration(0))
+}
+
+func benchmarkCacheGet(b *testing.B, opts ...Option) {
+	c := New[int, int](store.MapStore(0), opts...)
+	for k := 0; k < 1000; k++ {
+		_ = c.Set(k, k)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		k := 0
+		for pb.Next() {
+			_, _ = c.Get(k % 1000)
+			k++
+		}
+	})
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	benchmarkCacheGet(b)
+}
+
+func BenchmarkCache_GetWithStats(b *testing.B) {
+	benchmarkCacheGet(b, WithStats())
+}
+
+func BenchmarkCache_GetWithLatency(b *testing.B) {
+	benchmarkCacheGet(b, WithStats(), WithLatency())
+}
@@ -0,0 +1,142 @@
+package gcache
+
+import (
+	"errors"
+	"github.com/amerkurev/gcache/store"
+	"sync"
+	"sync/atomic"
+)
+
+// Event is passed to the hooks of a cache.
+type Event[K comparable, V any] struct {
+	// Op is the operation that caused the event, it is empty for evictions and expirations.
+	Op Op
+	// Key is the zero value for evictions and expirations, the store knows only the store key.
+	Key K
+	// Value is the value read, written, evicted or expired.
+	Value V
+	// StoreKey is the hashed key, it is empty if the key cannot be hashed or the operation is Clear.
+	StoreKey string
+	// Err is the error of OnError.
+	Err error
+}
+
+type hookKind int
+
+const (
+	hookHit hookKind = iota
+	hookMiss
+	hookSet
+	hookDelete
+	hookEvict
+	hookExpire
+	hookError
+	hookKinds
+)
+
+// hookTable is replaced on registration, so firing the hooks does not take a lock.
+type hookTable[K comparable, V any] [hookKinds][]func(Event[K, V])
+
+// hooks hold the hooks of a cache.
+type hooks[K comparable, V any] struct {
+	mx    sync.Mutex
+	table atomic.Value // *hookTable[K, V]
+}
+
+func (c *cache[K, V]) OnHit(fn func(Event[K, V]))    { c.on(hookHit, fn) }
+func (c *cache[K, V]) OnMiss(fn func(Event[K, V]))   { c.on(hookMiss, fn) }
+func (c *cache[K, V]) OnSet(fn func(Event[K, V]))    { c.on(hookSet, fn) }
+func (c *cache[K, V]) OnDelete(fn func(Event[K, V])) { c.on(hookDelete, fn) }
+func (c *cache[K, V]) OnEvict(fn func(Event[K, V]))  { c.on(hookEvict, fn) }
+func (c *cache[K, V]) OnExpire(fn func(Event[K, V])) { c.on(hookExpire, fn) }
+func (c *cache[K, V]) OnError(fn func(Event[K, V]))  { c.on(hookError, fn) }
+
+func (c *cache[K, V]) on(kind hookKind, fn func(Event[K, V])) {
+	c.hooks.mx.Lock()
+	defer c.hooks.mx.Unlock()
+
+	var h hookTable[K, V]
+	if old := c.loadHooks(); old != nil {
+		h = *old
+	}
+	h[kind] = append(h[kind][:len(h[kind]):len(h[kind])], fn)
+	c.hooks.table.Store(&h)
+}
+
+func (c *cache[K, V]) loadHooks() *hookTable[K, V] {
+	h, _ := c.hooks.table.Load().(*hookTable[K, V])
+	return h
+}
+
+// hooked reports whether there are hooks of the kind.
+func (c *cache[K, V]) hooked(kind hookKind) bool {
+	h := c.loadHooks()
+	return h != nil && len(h[kind]) > 0
+}
+
+func (c *cache[K, V]) emit(kind hookKind, e Event[K, V]) {
+	h := c.loadHooks()
+	if h == nil {
+		return
+	}
+	for _, fn := range h[kind] {
+		fn(e)
+	}
+}
+
+// emitRead fires the hook of the outcome of reading a key.
+func (c *cache[K, V]) emitRead(op Op, key K, k string, value V, err error) {
+	switch {
+	case err == nil:
+		c.emit(hookHit, Event[K, V]{Op: op, Key: key, Value: value, StoreKey: k})
+	case errors.Is(err, ErrNotFound):
+		c.emit(hookMiss, Event[K, V]{Op: op, Key: key, StoreKey: k})
+	default:
+		c.emitError(op, key, k, err)
+	}
+}
+
+// emitWrite fires the hook of the outcome of writing a key.
+func (c *cache[K, V]) emitWrite(op Op, key K, k string, value V, err error) {
+	if err != nil {
+		c.emitError(op, key, k, err)
+	} else {
+		c.emit(hookSet, Event[K, V]{Op: op, Key: key, Value: value, StoreKey: k})
+	}
+}
+
+// emitDelete fires the hook of the outcome of deleting a key.
+func (c *cache[K, V]) emitDelete(op Op, key K, k string, err error) {
+	if err != nil {
+		c.emitError(op, key, k, err)
+	} else {
+		c.emit(hookDelete, Event[K, V]{Op: op, Key: key, StoreKey: k})
+	}
+}
+
+func (c *cache[K, V]) emitError(op Op, key K, k string, err error) {
+	c.emit(hookError, Event[K, V]{Op: op, Key: key, StoreKey: k, Err: err})
+}
+
+// storeEvent fires the hook of an entry evicted or expired by the store.
+func (c *cache[K, V]) storeEvent(e store.Event) {
+	kind := hookEvict
+	if e.Kind == store.Expired {
+		kind = hookExpire
+	}
+	if !c.hooked(kind) {
+		return
+	}
+
+	var value V
+	data, _ := c.splitStaleHeader(e.Data)
+	raw, err := c.decompress(data)
+	if err == nil {
+		err = c.Unmarshal(raw, &value)
+	}
+	if err != nil {
+		c.emit(hookError, Event[K, V]{StoreKey: e.Key, Err: err})
+		return
+	}
+	c.emit(kind, Event[K, V]{Value: value, StoreKey: e.Key})
+}
@@ -0,0 +1,172 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"github.com/amerkurev/gcache/compressor"
+	"github.com/amerkurev/gcache/store"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hookRecorder records the events by the hook names.
+type hookRecorder[K comparable, V any] struct {
+	events []string
+	last   map[string]Event[K, V]
+}
+
+func recordHooks[K comparable, V any](c Cache[K, V]) *hookRecorder[K, V] {
+	r := &hookRecorder[K, V]{last: make(map[string]Event[K, V])}
+	on := func(name string) func(Event[K, V]) {
+		return func(e Event[K, V]) {
+			r.events = append(r.events, name)
+			r.last[name] = e
+		}
+	}
+	c.OnHit(on("hit"))
+	c.OnMiss(on("miss"))
+	c.OnSet(on("set"))
+	c.OnDelete(on("delete"))
+	c.OnEvict(on("evict"))
+	c.OnExpire(on("expire"))
+	c.OnError(on("error"))
+	return r
+}
+
+func TestCache_Hooks(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0))
+	r := recordHooks(c)
+
+	assert.Nil(t, c.Set(1, "John"))
+	k, _ := c.(*cache[int, string]).Hash(1)
+	assert.Equal(t, r.last["set"], Event[int, string]{Op: OpSet, Key: 1, Value: "John", StoreKey: k})
+
+	_, _ = c.Get(1)
+	assert.Equal(t, r.last["hit"], Event[int, string]{Op: OpGet, Key: 1, Value: "John", StoreKey: k})
+	_, _ = c.Get(2)
+	assert.Equal(t, r.last["miss"].Key, 2)
+	assert.Nil(t, c.Delete(1))
+	assert.Equal(t, r.last["delete"], Event[int, string]{Op: OpDelete, Key: 1, StoreKey: k})
+	assert.Equal(t, r.events, []string{"set", "hit", "miss", "delete"})
+
+	r.events = nil
+	_, _ = c.GetOrLoad(ctx, 3, func(context.Context, int) (string, error) {
+		return "Mary", nil
+	})
+	assert.Equal(t, r.events, []string{"miss", "set"})
+	assert.Equal(t, r.last["set"].Op, OpGetOrLoad)
+
+	r.events = nil
+	_ = c.SetMany(ctx, map[int]string{4: "four"})
+	_ = c.GetMany(ctx, []int{3, 5})
+	_ = c.DeleteMany(ctx, []int{3})
+	assert.Equal(t, r.events, []string{"set", "hit", "miss", "delete"})
+	assert.Equal(t, r.last["hit"].Op, OpGetMany)
+	assert.Equal(t, r.last["miss"].Key, 5)
+	assert.Equal(t, r.last["delete"].Op, OpDeleteMany)
+
+	r.events = nil
+	loadErr := errors.New("load failed")
+	_, err := c.GetOrLoad(ctx, 6, func(context.Context, int) (string, error) {
+		return "", loadErr
+	})
+	assert.True(t, errors.Is(err, loadErr))
+	assert.Equal(t, r.events, []string{"miss", "error"})
+	assert.Equal(t, r.last["error"].Err, loadErr)
+	assert.Equal(t, r.last["error"].Key, 6)
+
+	// the expired entries are reported by the store
+	r.events = nil
+	assert.Nil(t, c.SetWithTTL(7, "seven", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = c.Get(7)
+	assert.Equal(t, r.events, []string{"set", "expire", "miss"})
+	assert.Equal(t, r.last["expire"].Value, "seven")
+	assert.Equal(t, r.last["expire"].StoreKey, r.last["set"].StoreKey)
+}
+
+// clearFailingStore fails to clear.
+type clearFailingStore struct {
+	store.Store
+}
+
+func (clearFailingStore) Clear(context.Context) error {
+	return errors.New("clear failed")
+}
+
+func TestCache_HooksErrors(t *testing.T) {
+	c := New[complex128, string](clearFailingStore{store.MapStore(0)})
+	r := recordHooks(c)
+
+	assert.NotNil(t, c.Set(complex(10, 30), "complex"))
+	assert.Equal(t, r.events, []string{"error"})
+	assert.Equal(t, r.last["error"].Op, OpSet)
+	assert.Equal(t, r.last["error"].Key, complex(10, 30))
+	assert.Equal(t, r.last["error"].StoreKey, "")
+
+	assert.NotNil(t, c.Clear())
+	assert.Equal(t, r.last["error"].Op, OpClear)
+	assert.Equal(t, r.events, []string{"error", "error"})
+}
+
+func TestCache_HooksEvict(t *testing.T) {
+	s := store.BoundedStore(store.BoundedConfig{MaxEntries: 1})
+	c := New[int, int](s, WithNamespace("ns"))
+	r := recordHooks(c)
+	other := New[int, int](s, WithNamespace("other"))
+	otherHooks := recordHooks(other)
+
+	assert.Nil(t, c.Set(1, 10))
+	assert.Nil(t, c.Set(2, 20))
+	assert.Equal(t, r.events, []string{"set", "evict", "set"})
+	assert.Equal(t, r.last["evict"].Value, 10)
+	assert.Equal(t, r.last["evict"].Key, 0) // the typed key is not known
+
+	assert.Nil(t, other.Set(3, 30))
+	assert.Equal(t, r.events[3:], []string{"evict"})
+	assert.Equal(t, r.last["evict"].Value, 20)
+	assert.Equal(t, otherHooks.events, []string{"set"})
+
+	// the value that cannot be decoded is reported as an error
+	strs := New[int, string](s, WithNamespace("other"))
+	strHooks := recordHooks(strs)
+	assert.Nil(t, c.Set(4, 40))
+	assert.Equal(t, strHooks.events, []string{"error"})
+	assert.Equal(t, otherHooks.events, []string{"set", "evict"})
+}
+
+func TestCache_HooksEvictNamespaces(t *testing.T) {
+	s := store.BoundedStore(store.BoundedConfig{MaxEntries: 1})
+	root := New[int, int](s)
+	rootHooks := recordHooks(root)
+	strs := New[int, string](s, WithNamespace("ns"))
+	strHooks := recordHooks(strs)
+	nested := New[int, int](s, WithNamespace("ns:nested"))
+	nestedHooks := recordHooks(nested)
+
+	// the events of other namespaces are not decoded by the caches of another value type
+	assert.Nil(t, strs.Set(1, "a"))
+	assert.Nil(t, nested.Set(1, 10))
+	assert.Nil(t, root.Set(1, 20))
+	assert.Nil(t, strs.Set(2, "b"))
+	assert.Equal(t, strHooks.events, []string{"set", "evict", "set"})
+	assert.Equal(t, strHooks.last["evict"].Value, "a")
+	assert.Equal(t, nestedHooks.events, []string{"set", "evict"})
+	assert.Equal(t, nestedHooks.last["evict"].Value, 10)
+	assert.Equal(t, rootHooks.events, []string{"set", "evict"})
+	assert.Equal(t, rootHooks.last["evict"].Value, 20)
+}
+
+func TestCache_HooksEvictStale(t *testing.T) {
+	s := store.BoundedStore(store.BoundedConfig{MaxEntries: 1})
+	c := New[int, string](s, WithStale(time.Minute), WithCompression(&compressor.GzipCompressor{}, 10))
+	r := recordHooks(c)
+
+	assert.Nil(t, c.SetWithTTL(1, strings.Repeat("a", 100), time.Millisecond))
+	assert.Nil(t, c.Set(2, "b"))
+	assert.Equal(t, r.events, []string{"set", "evict", "set"})
+	assert.Equal(t, r.last["evict"].Value, strings.Repeat("a", 100))
+}
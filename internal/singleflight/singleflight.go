@@ -0,0 +1,114 @@
+package singleflight
+
+import (
+	"fmt"
+	"sync"
+)
+
+// call is an in-flight or completed Do call.
+type call[V any] struct {
+	wg   sync.WaitGroup
+	val  V
+	err  error
+	dups int
+	// chans receive the results of the DoChan callers
+	chans []chan<- Result[V]
+}
+
+// Result holds the results of Do, so they can be passed on a channel.
+type Result[V any] struct {
+	Val    V
+	Err    error
+	Shared bool
+}
+
+// Group represents a class of work and forms a namespace in which units of work
+// can be executed with duplicate suppression.
+type Group[V any] struct {
+	mx sync.Mutex
+	m  map[string]*call[V]
+}
+
+// Do executes and returns the results of the given function, making sure that only one execution
+// is in-flight for a given key at a time. If a duplicate comes in, the duplicate caller waits for
+// the original to complete and receives the same results. The return value shared reports whether
+// the results were given to multiple callers.
+func (g *Group[V]) Do(key string, fn func() (V, error)) (v V, err error, shared bool) {
+	g.mx.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		g.mx.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := new(call[V])
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mx.Unlock()
+
+	if r := g.doCall(c, key, fn); r != nil {
+		panic(r)
+	}
+	return c.val, c.err, c.dups > 0
+}
+
+// DoChan is like Do but returns a channel that receives the results when they are ready.
+// The function runs in its own goroutine, so the callers may stop waiting without stopping it.
+// A panic of the function is returned to the callers as PanicError.
+func (g *Group[V]) DoChan(key string, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	g.mx.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call[V])
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mx.Unlock()
+		return ch
+	}
+	c := &call[V]{chans: []chan<- Result[V]{ch}}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mx.Unlock()
+
+	go g.doCall(c, key, fn)
+	return ch
+}
+
+// doCall calls the function and returns the value of its panic, if any.
+func (g *Group[V]) doCall(c *call[V], key string, fn func() (V, error)) (r any) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			// waiters must not block forever if fn panics
+			if r = recover(); r != nil {
+				c.err = &PanicError{Value: r}
+			}
+		}
+
+		g.mx.Lock()
+		delete(g.m, key)
+		for _, ch := range c.chans {
+			ch <- Result[V]{Val: c.val, Err: c.err, Shared: c.dups > 0}
+		}
+		g.mx.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	normalReturn = true
+	return nil
+}
+
+// PanicError is returned to waiting callers when the function passed to Do panics.
+type PanicError struct {
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: function panicked: %v", e.Value)
+}
@@ -0,0 +1,124 @@
+package singleflight
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[string]
+	v, err, shared := g.Do("key", func() (string, error) {
+		return "bar", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, v, "bar")
+	assert.False(t, shared)
+}
+
+func TestGroup_DoErr(t *testing.T) {
+	var g Group[int]
+	someErr := errors.New("some error")
+	v, err, _ := g.Do("key", func() (int, error) {
+		return 0, someErr
+	})
+	assert.True(t, errors.Is(err, someErr))
+	assert.Equal(t, v, 0)
+}
+
+func TestGroup_DoDupSuppress(t *testing.T) {
+	var g Group[int]
+	var calls int32
+	release := make(chan struct{})
+
+	goroutines := 10
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do("key", func() (int, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 100, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, v, 100)
+		}()
+	}
+
+	// let the goroutines join the in-flight call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestGroup_DoPanic(t *testing.T) {
+	var g Group[int]
+	assert.Panics(t, func() {
+		_, _, _ = g.Do("key", func() (int, error) {
+			panic("some panic")
+		})
+	})
+
+	// the key must be released after panic
+	v, err, _ := g.Do("key", func() (int, error) {
+		return 1, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, v, 1)
+}
+
+func TestGroup_DoChan(t *testing.T) {
+	var g Group[int]
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 1, nil
+	}
+
+	first := g.DoChan("key", fn)
+	second := g.DoChan("key", fn)
+	done := make(chan struct{})
+	go func() {
+		v, err, shared := g.Do("key", fn)
+		assert.Nil(t, err)
+		assert.Equal(t, v, 1)
+		assert.True(t, shared)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// the callers do not wait for the function
+	select {
+	case <-first:
+		t.Fatal("unexpected results")
+	default:
+	}
+	close(release)
+	for _, ch := range []<-chan Result[int]{first, second} {
+		r := <-ch
+		assert.Nil(t, r.Err)
+		assert.Equal(t, r.Val, 1)
+		assert.True(t, r.Shared)
+	}
+	<-done
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestGroup_DoChanPanic(t *testing.T) {
+	var g Group[int]
+	r := <-g.DoChan("key", func() (int, error) {
+		panic("some panic")
+	})
+	var pe *PanicError
+	assert.True(t, errors.As(r.Err, &pe))
+	assert.Equal(t, pe.Value, "some panic")
+}
@@ -0,0 +1,27 @@
+package marshaler
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"reflect"
+)
+
+// CBORMarshaler is a marshaler that uses CBOR (RFC 8949).
+type CBORMarshaler struct{}
+
+// Marshal returns the CBOR encoding of any value.
+func (m *CBORMarshaler) Marshal(v any) ([]byte, error) {
+	b, err := cbor.Marshal(v)
+	if err != nil {
+		return nil, &MarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the CBOR data.
+func (m *CBORMarshaler) Unmarshal(b []byte, v any) error {
+	err := cbor.Unmarshal(b, v)
+	if err != nil {
+		return &UnmarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return nil
+}
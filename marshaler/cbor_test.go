@@ -0,0 +1,31 @@
+package marshaler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCBORMarshaler(t *testing.T) {
+	m := &CBORMarshaler{}
+	b, err := m.Marshal(100)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{0x18, 0x64})
+
+	b, err = m.Marshal(&testUser{Name: "John", Age: 30})
+	assert.Nil(t, err)
+
+	var u *testUser
+	err = m.Unmarshal(b, &u)
+	assert.Nil(t, err)
+	assert.Equal(t, u, &testUser{Name: "John", Age: 30})
+
+	var marshalError *MarshalError
+	_, err = m.Marshal(make(chan int))
+	assert.True(t, errors.As(err, &marshalError))
+
+	var unmarshalError *UnmarshalError
+	var i int
+	err = m.Unmarshal([]byte{0x61, 0x61}, &i)
+	assert.True(t, errors.As(err, &unmarshalError))
+}
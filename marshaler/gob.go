@@ -0,0 +1,30 @@
+package marshaler
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+)
+
+// GobMarshaler is a marshaler that uses encoding/gob. Every value is encoded with its type information,
+// so it is less compact than msgpack for small values.
+type GobMarshaler struct{}
+
+// Marshal returns the gob encoding of any value.
+func (m *GobMarshaler) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, &MarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the gob data.
+func (m *GobMarshaler) Unmarshal(b []byte, v any) error {
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+	if err != nil {
+		return &UnmarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return nil
+}
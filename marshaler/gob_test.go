@@ -0,0 +1,35 @@
+package marshaler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGobMarshaler(t *testing.T) {
+	m := &GobMarshaler{}
+	b, err := m.Marshal(&testUser{Name: "John", Age: 30})
+	assert.Nil(t, err)
+
+	var u *testUser
+	err = m.Unmarshal(b, &u)
+	assert.Nil(t, err)
+	assert.Equal(t, u, &testUser{Name: "John", Age: 30})
+
+	b, err = m.Marshal(map[string]int{"a": 1})
+	assert.Nil(t, err)
+
+	var v map[string]int
+	err = m.Unmarshal(b, &v)
+	assert.Nil(t, err)
+	assert.Equal(t, v, map[string]int{"a": 1})
+
+	var marshalError *MarshalError
+	_, err = m.Marshal(func() {})
+	assert.True(t, errors.As(err, &marshalError))
+
+	var unmarshalError *UnmarshalError
+	var s string
+	err = m.Unmarshal([]byte{1, 2, 3}, &s)
+	assert.True(t, errors.As(err, &unmarshalError))
+}
@@ -0,0 +1,27 @@
+package marshaler
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// JSONMarshaler is a marshaler that uses JSON, so the stored values are human-readable.
+type JSONMarshaler struct{}
+
+// Marshal returns the JSON encoding of any value.
+func (m *JSONMarshaler) Marshal(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, &MarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the JSON data.
+func (m *JSONMarshaler) Unmarshal(b []byte, v any) error {
+	err := json.Unmarshal(b, v)
+	if err != nil {
+		return &UnmarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package marshaler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testUser struct {
+	Name string
+	Age  int
+}
+
+func TestJSONMarshaler(t *testing.T) {
+	m := &JSONMarshaler{}
+	b, err := m.Marshal(&testUser{Name: "John", Age: 30})
+	assert.Nil(t, err)
+	assert.Equal(t, string(b), `{"Name":"John","Age":30}`)
+
+	var u *testUser
+	err = m.Unmarshal(b, &u)
+	assert.Nil(t, err)
+	assert.Equal(t, u, &testUser{Name: "John", Age: 30})
+
+	var marshalError *MarshalError
+	_, err = m.Marshal(make(chan int))
+	assert.True(t, errors.As(err, &marshalError))
+	assert.Equal(t, err.Error(), "cannot serialize object of type chan int: json: unsupported type: chan int")
+
+	var unmarshalError *UnmarshalError
+	var i int
+	err = m.Unmarshal([]byte(`"a"`), &i)
+	assert.True(t, errors.As(err, &unmarshalError))
+}
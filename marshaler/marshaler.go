@@ -18,7 +18,7 @@ type MarshalError struct {
 }
 
 func (e *MarshalError) Error() string {
-	return "cannot serialize object of type " + e.Type.String() +
+	return "cannot serialize object of type " + typeName(e.Type) +
 		": " + e.Err.Error()
 }
 
@@ -32,13 +32,21 @@ type UnmarshalError struct {
 }
 
 func (e *UnmarshalError) Error() string {
-	return "cannot deserialize object of type " + e.Type.String() +
+	return "cannot deserialize object of type " + typeName(e.Type) +
 		": " + e.Err.Error()
 }
 
 // Unwrap returns the underlying error.
 func (e *UnmarshalError) Unwrap() error { return e.Err }
 
+// typeName returns the name of the type, the type is nil for nil interface values.
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.String()
+}
+
 // MsgpackMarshaler is a default marshaler that uses msgpack for marshaling and unmarshaling.
 type MsgpackMarshaler struct{}
 
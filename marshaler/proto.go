@@ -0,0 +1,51 @@
+package marshaler
+
+import (
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+)
+
+// ErrNotProtoMessage indicates that the value is not a protocol buffers message.
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// ProtoMarshaler is a marshaler of protocol buffers messages, e.g. gcache.New[int, *pb.User].
+type ProtoMarshaler struct{}
+
+// Marshal returns the wire-format encoding of the message.
+func (m *ProtoMarshaler) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, &MarshalError{Type: reflect.TypeOf(v), Err: ErrNotProtoMessage}
+	}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, &MarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the wire-format data into the message, or into the pointer to a message
+// that is allocated if nil.
+func (m *ProtoMarshaler) Unmarshal(b []byte, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+			if rv.Elem().IsNil() {
+				rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+			}
+			msg, ok = rv.Elem().Interface().(proto.Message)
+		}
+	}
+	if !ok {
+		return &UnmarshalError{Type: reflect.TypeOf(v), Err: ErrNotProtoMessage}
+	}
+
+	err := proto.Unmarshal(b, msg)
+	if err != nil {
+		return &UnmarshalError{Type: reflect.TypeOf(v), Err: err}
+	}
+	return nil
+}
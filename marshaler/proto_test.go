@@ -0,0 +1,38 @@
+package marshaler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func TestProtoMarshaler(t *testing.T) {
+	m := &ProtoMarshaler{}
+	b, err := m.Marshal(wrapperspb.String("some value"))
+	assert.Nil(t, err)
+
+	// pointer to a nil message is allocated
+	var v *wrapperspb.StringValue
+	err = m.Unmarshal(b, &v)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(v, wrapperspb.String("some value")))
+
+	msg := &wrapperspb.StringValue{}
+	err = m.Unmarshal(b, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, msg.GetValue(), "some value")
+
+	_, err = m.Marshal("not a message")
+	assert.True(t, errors.Is(err, ErrNotProtoMessage))
+	assert.Equal(t, err.Error(), "cannot serialize object of type string: value is not a proto.Message")
+
+	var s string
+	err = m.Unmarshal(b, &s)
+	assert.True(t, errors.Is(err, ErrNotProtoMessage))
+
+	var unmarshalError *UnmarshalError
+	err = m.Unmarshal([]byte{0xff}, &v)
+	assert.True(t, errors.As(err, &unmarshalError))
+}
@@ -0,0 +1,37 @@
+package marshaler
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrUnsupportedType indicates that the marshaler cannot encode or decode values of the type.
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// RawMarshaler stores []byte and string values as they are. A []byte value is neither copied on Marshal
+// nor on Unmarshal, so it must not be modified after it was set or got from the cache.
+type RawMarshaler struct{}
+
+// Marshal returns the bytes of []byte or string value.
+func (m *RawMarshaler) Marshal(v any) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	}
+	return nil, &MarshalError{Type: reflect.TypeOf(v), Err: ErrUnsupportedType}
+}
+
+// Unmarshal sets the bytes to *[]byte or *string value.
+func (m *RawMarshaler) Unmarshal(b []byte, v any) error {
+	switch t := v.(type) {
+	case *[]byte:
+		*t = b
+		return nil
+	case *string:
+		*t = string(b)
+		return nil
+	}
+	return &UnmarshalError{Type: reflect.TypeOf(v), Err: ErrUnsupportedType}
+}
@@ -0,0 +1,40 @@
+package marshaler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRawMarshaler(t *testing.T) {
+	m := &RawMarshaler{}
+	data := []byte{1, 2, 3}
+	b, err := m.Marshal(data)
+	assert.Nil(t, err)
+	assert.Same(t, &b[0], &data[0])
+
+	var v []byte
+	err = m.Unmarshal(b, &v)
+	assert.Nil(t, err)
+	assert.Same(t, &v[0], &data[0])
+
+	b, err = m.Marshal("some value")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("some value"))
+
+	var s string
+	err = m.Unmarshal(b, &s)
+	assert.Nil(t, err)
+	assert.Equal(t, s, "some value")
+
+	_, err = m.Marshal(100)
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+	assert.Equal(t, err.Error(), "cannot serialize object of type int: unsupported type")
+
+	_, err = m.Marshal(nil)
+	assert.Equal(t, err.Error(), "cannot serialize object of type <nil>: unsupported type")
+
+	var i int
+	err = m.Unmarshal(b, &i)
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+}
@@ -0,0 +1,189 @@
+package gcache
+
+import (
+	"context"
+	"github.com/amerkurev/gcache/store"
+	"time"
+)
+
+// Request is a store operation passed through the middleware chain of a cache.
+type Request struct {
+	// Op is OpGet, OpSet, OpDelete, OpClear, OpGetMany, OpSetMany or OpDeleteMany,
+	// the other cache operations are made of them.
+	Op Op
+	// Key is the typed key of the single key operations, it is nil for OpClear and the batch operations.
+	Key any
+	// StoreKey is the hashed key, it is empty for OpClear and the batch operations.
+	StoreKey string
+	// Data is the marshaled and compressed value of OpSet.
+	Data []byte
+	// TTL is the time-to-live of OpSet.
+	TTL time.Duration
+
+	// Keys are the typed keys of the batch operations.
+	Keys []any
+	// StoreKeys are the hashed keys of OpGetMany and OpDeleteMany.
+	StoreKeys []string
+	// Entries are the hashed keys and the values of OpSetMany.
+	Entries []store.Entry
+	// Values are set by the handler of OpGetMany to the data of every key.
+	Values [][]byte
+	// Errs are set by the handler of a batch operation to the errors of every key.
+	Errs []error
+}
+
+// Handler performs the store operation. It returns the data read by OpGet, the results of the batch operations
+// are set to the request. The error returned for a batch operation fails every key.
+type Handler func(ctx context.Context, req *Request) ([]byte, error)
+
+// Middleware wraps a handler, e.g. to log, retry or reject the store operations.
+type Middleware func(next Handler) Handler
+
+// PerKey adapts a middleware of the single key operations to the batch operations, which are split
+// into the requests of every key. Neither the middleware nor the handlers after it see the batches.
+func PerKey(mw Middleware) Middleware {
+	return func(next Handler) Handler {
+		h := mw(next)
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			switch req.Op {
+			case OpGetMany:
+				req.Values = make([][]byte, len(req.StoreKeys))
+				req.Errs = make([]error, len(req.StoreKeys))
+				for i, k := range req.StoreKeys {
+					req.Values[i], req.Errs[i] = h(ctx, &Request{Op: OpGet, Key: req.Keys[i], StoreKey: k})
+				}
+			case OpSetMany:
+				req.Errs = make([]error, len(req.Entries))
+				for i, e := range req.Entries {
+					_, req.Errs[i] = h(ctx, &Request{Op: OpSet, Key: req.Keys[i], StoreKey: e.Key, Data: e.Data, TTL: e.TTL})
+				}
+			case OpDeleteMany:
+				req.Errs = make([]error, len(req.StoreKeys))
+				for i, k := range req.StoreKeys {
+					_, req.Errs[i] = h(ctx, &Request{Op: OpDelete, Key: req.Keys[i], StoreKey: k})
+				}
+			default:
+				return h(ctx, req)
+			}
+			return nil, nil
+		}
+	}
+}
+
+// chain wraps the handler by the middlewares, the first middleware is the outermost.
+func chain(h Handler, mws []Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// handle performs the request on the store, it is the innermost handler.
+func (c *cache[K, V]) handle(ctx context.Context, req *Request) ([]byte, error) {
+	switch req.Op {
+	case OpGet:
+		return c.Store.Get(ctx, req.StoreKey)
+	case OpSet:
+		return nil, store.SetWithTTL(ctx, c.Store, req.StoreKey, req.Data, req.TTL)
+	case OpDelete:
+		return nil, c.Store.Delete(ctx, req.StoreKey)
+	case OpClear:
+		return nil, c.Store.Clear(ctx)
+	case OpGetMany:
+		req.Values, req.Errs = store.GetMany(ctx, c.Store, req.StoreKeys)
+	case OpSetMany:
+		req.Errs = store.SetMany(ctx, c.Store, req.Entries)
+	case OpDeleteMany:
+		req.Errs = store.DeleteMany(ctx, c.Store, req.StoreKeys)
+	}
+	return nil, nil
+}
+
+func (c *cache[K, V]) storeGet(ctx context.Context, key K, k string) ([]byte, error) {
+	if c.handler == nil {
+		return c.Store.Get(ctx, k)
+	}
+	return c.handler(ctx, &Request{Op: OpGet, Key: key, StoreKey: k})
+}
+
+func (c *cache[K, V]) storeSet(ctx context.Context, key K, k string, data []byte, ttl time.Duration) error {
+	if c.handler == nil {
+		return store.SetWithTTL(ctx, c.Store, k, data, ttl)
+	}
+	_, err := c.handler(ctx, &Request{Op: OpSet, Key: key, StoreKey: k, Data: data, TTL: ttl})
+	return err
+}
+
+func (c *cache[K, V]) storeDelete(ctx context.Context, key K, k string) error {
+	if c.handler == nil {
+		return c.Store.Delete(ctx, k)
+	}
+	_, err := c.handler(ctx, &Request{Op: OpDelete, Key: key, StoreKey: k})
+	return err
+}
+
+func (c *cache[K, V]) storeClear(ctx context.Context) error {
+	if c.handler == nil {
+		return c.Store.Clear(ctx)
+	}
+	_, err := c.handler(ctx, &Request{Op: OpClear})
+	return err
+}
+
+// The batch operations pass through the middleware chain as a single request, so the store batches are used.
+
+func (c *cache[K, V]) storeGetMany(ctx context.Context, keys []K, hashed []string) ([][]byte, []error) {
+	if c.handler == nil {
+		return store.GetMany(ctx, c.Store, hashed)
+	}
+	req := &Request{Op: OpGetMany, Keys: anyKeys(keys), StoreKeys: hashed}
+	_, err := c.handler(ctx, req)
+	errs := batchErrs(req, err, len(hashed))
+	data := make([][]byte, len(hashed))
+	copy(data, req.Values)
+	for i := len(req.Values); i < len(errs); i++ {
+		if errs[i] == nil {
+			errs[i] = store.ErrNotFound
+		}
+	}
+	return data, errs
+}
+
+func (c *cache[K, V]) storeSetMany(ctx context.Context, keys []K, entries []store.Entry) []error {
+	if c.handler == nil {
+		return store.SetMany(ctx, c.Store, entries)
+	}
+	req := &Request{Op: OpSetMany, Keys: anyKeys(keys), Entries: entries}
+	_, err := c.handler(ctx, req)
+	return batchErrs(req, err, len(entries))
+}
+
+func (c *cache[K, V]) storeDeleteMany(ctx context.Context, keys []K, hashed []string) []error {
+	if c.handler == nil {
+		return store.DeleteMany(ctx, c.Store, hashed)
+	}
+	req := &Request{Op: OpDeleteMany, Keys: anyKeys(keys), StoreKeys: hashed}
+	_, err := c.handler(ctx, req)
+	return batchErrs(req, err, len(hashed))
+}
+
+func anyKeys[K comparable](keys []K) []any {
+	a := make([]any, len(keys))
+	for i, key := range keys {
+		a[i] = key
+	}
+	return a
+}
+
+// batchErrs returns the errors of every key of the batch request, the error of the handler fails every key.
+func batchErrs(req *Request, err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		if err != nil {
+			errs[i] = err
+		} else if i < len(req.Errs) {
+			errs[i] = req.Errs[i]
+		}
+	}
+	return errs
+}
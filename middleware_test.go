@@ -0,0 +1,143 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/amerkurev/gcache/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// logMiddleware logs the requests and their outcomes.
+func logMiddleware(name string, log *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			*log = append(*log, fmt.Sprintf("%s %s %v", name, req.Op, req.Key))
+			b, err := next(ctx, req)
+			*log = append(*log, fmt.Sprintf("%s %s done %v", name, req.Op, err))
+			return b, err
+		}
+	}
+}
+
+func TestCache_Middleware(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	var requests []Request
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			b, err := next(ctx, req)
+			requests = append(requests, *req)
+			return b, err
+		}
+	}
+	c := New[int, string](store.MapStore(0),
+		WithMiddleware(logMiddleware("outer", &log), logMiddleware("inner", &log)), WithMiddleware(record))
+
+	assert.Nil(t, c.SetWithTTL(1, "John", time.Minute))
+	assert.Equal(t, log, []string{"outer set 1", "inner set 1", "inner set done <nil>", "outer set done <nil>"})
+
+	k, _ := c.(*cache[int, string]).Hash(1)
+	data, _ := c.(*cache[int, string]).Marshal("John")
+	assert.Equal(t, requests, []Request{{Op: OpSet, Key: 1, StoreKey: k, Data: data, TTL: time.Minute}})
+
+	v, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, v, "John")
+	assert.Nil(t, c.Delete(1))
+	assert.Nil(t, c.Clear())
+	assert.Equal(t, requests[1:], []Request{
+		{Op: OpGet, Key: 1, StoreKey: k},
+		{Op: OpDelete, Key: 1, StoreKey: k},
+		{Op: OpClear},
+	})
+
+	// the other operations are made of the store operations, the batches are single requests
+	requests = nil
+	_, err = c.GetOrLoad(ctx, 2, func(context.Context, int) (string, error) {
+		return "Mary", nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, c.SetMany(ctx, map[int]string{3: "three"}))
+	_ = c.GetMany(ctx, []int{2, 3})
+	assert.Nil(t, c.DeleteMany(ctx, []int{2, 3}))
+
+	ops := make([]string, len(requests))
+	for i, req := range requests {
+		ops[i] = fmt.Sprintf("%s %v %v", req.Op, req.Key, req.Keys)
+	}
+	assert.Equal(t, ops, []string{"get 2 []", "set 2 []", "set_many <nil> [3]", "get_many <nil> [2 3]",
+		"delete_many <nil> [2 3]"})
+
+	k2, _ := c.(*cache[int, string]).Hash(2)
+	k3, _ := c.(*cache[int, string]).Hash(3)
+	assert.Equal(t, requests[3].StoreKeys, []string{k2, k3})
+	assert.Equal(t, len(requests[3].Values), 2)
+	assert.Equal(t, requests[3].Errs, []error{nil, nil})
+}
+
+func TestCache_MiddlewareBatch(t *testing.T) {
+	ctx := context.Background()
+	errUnavailable := errors.New("unavailable")
+	var batches int
+	fail := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			if req.Keys != nil {
+				batches++
+				return nil, errUnavailable
+			}
+			return next(ctx, req)
+		}
+	}
+	c := New[int, string](store.MapStore(0), WithMiddleware(fail))
+	assert.Nil(t, c.Set(1, "one"))
+
+	// the error of the batch request fails every key
+	for _, r := range c.GetMany(ctx, []int{1, 2}) {
+		assert.True(t, errors.Is(r.Err, errUnavailable))
+	}
+	failed := c.SetMany(ctx, map[int]string{2: "two"})
+	assert.True(t, errors.Is(failed[2], errUnavailable))
+	failed = c.DeleteMany(ctx, []int{1})
+	assert.True(t, errors.Is(failed[1], errUnavailable))
+	assert.Equal(t, batches, 3)
+
+	// the batches are split into the requests of every key
+	batches = 0
+	c = New[int, string](store.MapStore(0), WithMiddleware(PerKey(fail)))
+	assert.Nil(t, c.SetMany(ctx, map[int]string{1: "one", 2: "two"}))
+	results := c.GetMany(ctx, []int{1, 2, 3})
+	assert.Equal(t, results[1].Value, "two")
+	assert.True(t, errors.Is(results[2].Err, ErrNotFound))
+	assert.Nil(t, c.DeleteMany(ctx, []int{1, 2}))
+	assert.Equal(t, batches, 0)
+}
+
+func TestCache_MiddlewareShortCircuit(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+	auth := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			if key, ok := req.Key.(string); ok && key == "secret" {
+				return nil, errForbidden
+			}
+			return next(ctx, req)
+		}
+	}
+	c := New[string, int](store.MapStore(0), WithMiddleware(PerKey(auth)), WithStats())
+
+	assert.True(t, errors.Is(c.Set("secret", 1), errForbidden))
+	_, err := c.Get("secret")
+	assert.True(t, errors.Is(err, errForbidden))
+	assert.Nil(t, c.Set("public", 1))
+
+	results := c.GetMany(context.Background(), []string{"public", "secret"})
+	assert.Nil(t, results[0].Err)
+	assert.True(t, errors.Is(results[1].Err, errForbidden))
+
+	s, _ := c.Stats()
+	assert.Equal(t, s.ErrWriteCount, 1)
+	assert.Equal(t, s.ErrReadCount, 2)
+	assert.Equal(t, s.Hits, 1)
+}
@@ -0,0 +1,170 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"github.com/amerkurev/gcache/stats"
+	"time"
+)
+
+// Op is the name of a cache operation.
+type Op string
+
+const (
+	OpGet        Op = "get"
+	OpSet        Op = "set"
+	OpDelete     Op = "delete"
+	OpClear      Op = "clear"
+	OpGetOrLoad  Op = "get_or_load"
+	OpGetMany    Op = "get_many"
+	OpSetMany    Op = "set_many"
+	OpDeleteMany Op = "delete_many"
+)
+
+// Reads reports whether the operation reads from the store, otherwise it writes.
+func (op Op) Reads() bool {
+	return op == OpGet || op == OpGetOrLoad || op == OpGetMany
+}
+
+// Observation is the outcome of a cache operation. Batch operations count every key.
+type Observation struct {
+	Op       Op
+	Hits     int
+	Misses   int
+	Bytes    int   // bytes read from or written to the store
+	Errors   int   // failed keys, or 1 if the operation failed
+	Err      error // the first error, a miss is not an error
+	Duration time.Duration
+
+	// time spent in the phases of the operation
+	Hash    time.Duration
+	Marshal time.Duration // marshaling or unmarshaling along with the compression
+	Store   time.Duration
+
+	timed bool
+	start time.Time
+	last  time.Time
+	end   func(Observation)
+}
+
+// Observer is notified about every cache operation. Start is called before the operation,
+// the returned context is passed to the store, and the returned function is called when the operation ends.
+type Observer interface {
+	Start(ctx context.Context, op Op) (context.Context, func(Observation))
+}
+
+// read accounts the outcome of reading a single key.
+func (o *Observation) read(n int, err error) {
+	switch {
+	case err == nil:
+		o.Hits++
+		o.Bytes += n
+	case errors.Is(err, ErrNotFound):
+		o.Misses++
+	default:
+		o.fail(err)
+	}
+}
+
+// write accounts the outcome of writing or deleting a single key.
+func (o *Observation) write(n int, err error) {
+	if err != nil {
+		o.fail(err)
+	} else {
+		o.Bytes += n
+	}
+}
+
+// startTiming starts timing the phases of the operation.
+func (o *Observation) startTiming(start time.Time) {
+	o.timed = true
+	o.last = start
+}
+
+// lap returns the time elapsed since the previous lap or the start of the timing,
+// so a phase ending with the lap is measured without reading the clock at its start.
+func (o *Observation) lap() time.Duration {
+	if !o.timed {
+		return 0
+	}
+	now := time.Now()
+	d := now.Sub(o.last)
+	o.last = now
+	return d
+}
+
+func (o *Observation) fail(err error) {
+	o.Errors++
+	if o.Err == nil {
+		o.Err = err
+	}
+}
+
+type observers []Observer
+
+func (obs observers) Start(ctx context.Context, op Op) (context.Context, func(Observation)) {
+	ends := make([]func(Observation), len(obs))
+	for i, o := range obs {
+		ctx, ends[i] = o.Start(ctx, op)
+	}
+	return ctx, func(o Observation) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](o)
+		}
+	}
+}
+
+// observe starts observing the operation, it is ended by done.
+// The operation is timed if there is an observer or the latency is collected along with the stats.
+func (c *cache[K, V]) observe(ctx context.Context, op Op, obs *Observation) context.Context {
+	obs.Op = op
+	if c.observer == nil && !(c.latency && c.Enabled()) {
+		return ctx
+	}
+
+	if c.observer != nil {
+		ctx, obs.end = c.observer.Start(ctx, op)
+	}
+	obs.start = time.Now()
+	obs.startTiming(obs.start)
+	return ctx
+}
+
+// done ends observing the operation.
+func (c *cache[K, V]) done(obs *Observation) {
+	if obs.timed {
+		obs.Duration = time.Since(obs.start)
+	}
+	if c.Enabled() {
+		c.RecordOp()
+		if obs.timed && c.latency {
+			c.recordLatency(obs)
+		}
+	}
+	if end := obs.end; end != nil {
+		obs.end = nil
+		end(*obs)
+	}
+}
+
+// recordLatency records the latency of single key operations into the stats.
+func (c *cache[K, V]) recordLatency(obs *Observation) {
+	var op stats.LatencyOp
+	switch {
+	case obs.Op == OpGet && obs.Hits > 0:
+		op = stats.LatencyGetHit
+	case obs.Op == OpGet && obs.Misses > 0:
+		op = stats.LatencyGetMiss
+	case obs.Op == OpGet:
+		op = stats.LatencyGet
+	case obs.Op == OpSet:
+		op = stats.LatencySet
+	case obs.Op == OpDelete:
+		op = stats.LatencyDelete
+	case obs.Op == OpClear:
+		op = stats.LatencyClear
+	default:
+		return
+	}
+	c.RecordLatency(op, stats.Phases{Total: obs.Duration, Hash: obs.Hash, Marshal: obs.Marshal, Store: obs.Store})
+}
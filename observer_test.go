@@ -0,0 +1,97 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"github.com/amerkurev/gcache/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ctxKey struct{}
+
+type recordingObserver struct {
+	name         string
+	calls        *[]string
+	observations []Observation
+}
+
+func (r *recordingObserver) Start(ctx context.Context, op Op) (context.Context, func(Observation)) {
+	*r.calls = append(*r.calls, "start "+r.name)
+	ctx = context.WithValue(ctx, ctxKey{}, op)
+	return ctx, func(o Observation) {
+		*r.calls = append(*r.calls, "end "+r.name)
+		r.observations = append(r.observations, o)
+	}
+}
+
+// contextStore fails if the context does not carry the operation.
+type contextStore struct {
+	store.Store
+}
+
+func (s contextStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if ctx.Value(ctxKey{}) == nil {
+		return nil, errors.New("context is not passed")
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func TestCache_Observer(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	first := &recordingObserver{name: "first", calls: &calls}
+	second := &recordingObserver{name: "second", calls: &calls}
+	c := New[int, string](contextStore{store.MapStore(0)}, WithObserver(first), WithObserver(second))
+
+	assert.Nil(t, c.Set(1, "John"))
+	assert.Equal(t, calls, []string{"start first", "start second", "end second", "end first"})
+
+	_, err := c.Get(1)
+	assert.Nil(t, err)
+	_, err = c.Get(2)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	v, err := c.GetOrLoad(ctx, 3, func(context.Context, int) (string, error) {
+		return "Mary", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, v, "Mary")
+
+	results := c.GetMany(ctx, []int{1, 2, 3})
+	assert.Len(t, results, 3)
+	assert.Nil(t, c.SetMany(ctx, map[int]string{4: "a", 5: "b"}))
+	assert.Nil(t, c.DeleteMany(ctx, []int{4, 5}))
+	assert.Nil(t, c.Clear())
+
+	obs := first.observations
+	assert.Equal(t, obs, second.observations)
+	assert.Len(t, obs, 8)
+	for _, o := range obs {
+		assert.Nil(t, o.Err)
+	}
+	assert.Equal(t, obs[0].Op, OpSet)
+	assert.Equal(t, obs[0].Bytes, 5)
+	assert.Equal(t, obs[1].Op, OpGet)
+	assert.Equal(t, obs[1].Hits, 1)
+	assert.Equal(t, obs[1].Bytes, 5)
+	assert.LessOrEqual(t, obs[1].Hash+obs[1].Marshal+obs[1].Store, obs[1].Duration)
+	assert.Equal(t, obs[2].Misses, 1)
+	assert.Equal(t, obs[3].Op, OpGetOrLoad)
+	assert.Equal(t, obs[3].Misses, 1)
+	assert.Equal(t, obs[4].Op, OpGetMany)
+	assert.Equal(t, obs[4].Hits, 2)
+	assert.Equal(t, obs[4].Misses, 1)
+	assert.Equal(t, obs[5].Op, OpSetMany)
+	assert.Equal(t, obs[5].Bytes, 4)
+	assert.Equal(t, obs[6].Op, OpDeleteMany)
+	assert.Equal(t, obs[7].Op, OpClear)
+
+	// errors
+	failing := &recordingObserver{name: "failing", calls: &calls}
+	u := New[int, any](store.MapStore(0), WithObserver(failing))
+	err = u.Set(1, func() {})
+	assert.NotNil(t, err)
+	assert.Equal(t, failing.observations[0].Errors, 1)
+	assert.Equal(t, failing.observations[0].Err, err)
+}
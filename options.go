@@ -0,0 +1,147 @@
+package gcache
+
+import (
+	"github.com/amerkurev/gcache/compressor"
+	"github.com/amerkurev/gcache/hasher"
+	"github.com/amerkurev/gcache/marshaler"
+	"time"
+)
+
+// Option configures a cache created by New.
+type Option func(*options)
+
+type options struct {
+	hasher    hasher.Hasher
+	marshaler marshaler.Marshaler
+	useStats  bool
+	latency   bool
+	namespace string
+
+	compressor        compressor.Compressor
+	compressThreshold int
+
+	staleGrace time.Duration
+
+	observers   observers
+	middlewares []Middleware
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		hasher:    &hasher.MsgpackHasher{},
+		marshaler: &marshaler.MsgpackMarshaler{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithHasher sets the hasher that turns keys into the store keys. MsgpackHasher is used by default.
+func WithHasher(h hasher.Hasher) Option {
+	return func(o *options) {
+		o.hasher = h
+	}
+}
+
+// WithMarshaler sets the marshaler of values. MsgpackMarshaler is used by default.
+func WithMarshaler(m marshaler.Marshaler) Option {
+	return func(o *options) {
+		o.marshaler = m
+	}
+}
+
+// WithStats enables collecting metrics from the start, like calling UseStats.
+func WithStats() Option {
+	return func(o *options) {
+		o.useStats = true
+	}
+}
+
+// WithLatency adds the latency of operations to the stats collected by WithStats or UseStats.
+// It is not collected by default, since timing an operation reads the clock several times.
+func WithLatency() Option {
+	return func(o *options) {
+		o.latency = true
+	}
+}
+
+// WithNamespace partitions the store, so that caches with different namespaces do not overwrite
+// each other in the same store, and Clear removes only the entries of the namespace.
+// A custom store has to implement store.NamespaceStore to clear a namespace.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithCompression compresses the marshaled values that are not shorter than the threshold in bytes.
+// The compressed data is stored with a header, so the entries written before the compression was enabled,
+// or compressed by another built-in algorithm, are still read. Msgpack marshaled values never look like
+// the header, other marshalers must not produce values that start with the byte 0xc1 to keep the old entries readable.
+func WithCompression(c compressor.Compressor, threshold int) Option {
+	return func(o *options) {
+		o.compressor = c
+		o.compressThreshold = threshold
+	}
+}
+
+// WithStale keeps the values written with a time-to-live in the store for the grace period after they expire.
+// During the grace period the stale value is returned, and a single refresh by the loader of GetOrLoad or SetLoader
+// is started in the background. If the refresh fails, the stale value is returned until the grace period ends,
+// and the key is not refreshed again for 5 seconds.
+// The values are stored with a header of 18 bytes, which starts with the byte 0xc1 like the compression header,
+// so the values written before the option was set are read unless they start with the bytes 0xc1 0x80.
+func WithStale(grace time.Duration) Option {
+	return func(o *options) {
+		o.staleGrace = grace
+	}
+}
+
+// WithObserver adds the observer of the cache operations, e.g. the metrics collector.
+// The observers are started in the order they were added.
+func WithObserver(obs Observer) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, obs)
+	}
+}
+
+// WithMiddleware adds the middlewares of the store operations. The first middleware
+// added is the outermost, so it is the first to see the request.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, mws...)
+	}
+}
+
+func (o options) observer() Observer {
+	switch len(o.observers) {
+	case 0:
+		return nil
+	case 1:
+		return o.observers[0]
+	}
+	return o.observers
+}
+
+// SetOption configures a single write operation.
+type SetOption func(*setOptions)
+
+type setOptions struct {
+	ttl time.Duration
+}
+
+func newSetOptions(opts []SetOption) setOptions {
+	var o setOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTTL sets the time-to-live of the entry. A zero or negative ttl means that the entry never expires.
+func WithTTL(ttl time.Duration) SetOption {
+	return func(o *setOptions) {
+		o.ttl = ttl
+	}
+}
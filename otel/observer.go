@@ -0,0 +1,136 @@
+package otel
+
+import (
+	"context"
+	"github.com/amerkurev/gcache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer and the meter of gcache.
+const instrumentationName = "github.com/amerkurev/gcache/otel"
+
+// Config configures an OpenTelemetry observer.
+type Config struct {
+	// Cache is the name of the cache, e.g. "users".
+	Cache string
+	// Store is the type of the cache store, e.g. "redis".
+	Store string
+	// TracerProvider is the global tracer provider by default.
+	TracerProvider trace.TracerProvider
+	// MeterProvider is the global meter provider by default.
+	MeterProvider metric.MeterProvider
+}
+
+type observer struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+
+	duration instrument.Float64Histogram
+	hits     instrument.Int64Counter
+	misses   instrument.Int64Counter
+	bytes    instrument.Int64Counter
+	errors   instrument.Int64Counter
+}
+
+// NewObserver creates an observer that traces every cache operation with a span
+// and records the metrics of the operations. It is added to the cache by gcache.WithObserver.
+func NewObserver(cfg Config) (gcache.Observer, error) {
+	tp := cfg.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	mp := cfg.MeterProvider
+	if mp == nil {
+		mp = global.MeterProvider()
+	}
+
+	o := &observer{
+		tracer: tp.Tracer(instrumentationName),
+		attrs: []attribute.KeyValue{
+			attribute.String("gcache.cache", cfg.Cache),
+			attribute.String("gcache.store", cfg.Store),
+		},
+	}
+
+	var err error
+	meter := mp.Meter(instrumentationName)
+	if o.duration, err = meter.Float64Histogram("gcache.operation.duration",
+		instrument.WithUnit("ms"), instrument.WithDescription("Latency of cache operations.")); err != nil {
+		return nil, err
+	}
+	if o.hits, err = meter.Int64Counter("gcache.hits",
+		instrument.WithDescription("Number of keys found in the cache.")); err != nil {
+		return nil, err
+	}
+	if o.misses, err = meter.Int64Counter("gcache.misses",
+		instrument.WithDescription("Number of keys not found in the cache.")); err != nil {
+		return nil, err
+	}
+	if o.bytes, err = meter.Int64Counter("gcache.bytes",
+		instrument.WithUnit("By"), instrument.WithDescription("Number of bytes read from or written to the store.")); err != nil {
+		return nil, err
+	}
+	if o.errors, err = meter.Int64Counter("gcache.errors",
+		instrument.WithDescription("Number of failed keys of cache operations.")); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *observer) Start(ctx context.Context, op gcache.Op) (context.Context, func(gcache.Observation)) {
+	ctx, span := o.tracer.Start(ctx, "gcache."+string(op),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(o.with(attribute.String("gcache.op", string(op)))...),
+	)
+	return ctx, func(obs gcache.Observation) {
+		o.end(ctx, span, obs)
+	}
+}
+
+func (o *observer) end(ctx context.Context, span trace.Span, obs gcache.Observation) {
+	attrs := o.with(attribute.String("gcache.op", string(obs.Op)))
+
+	span.SetAttributes(
+		attribute.Int("gcache.hits", obs.Hits),
+		attribute.Int("gcache.misses", obs.Misses),
+		attribute.Int("gcache.bytes", obs.Bytes),
+	)
+	if obs.Op == gcache.OpGet || obs.Op == gcache.OpGetOrLoad {
+		span.SetAttributes(attribute.Bool("gcache.hit", obs.Hits > 0))
+	}
+	if obs.Err != nil {
+		span.RecordError(obs.Err)
+		span.SetStatus(codes.Error, obs.Err.Error())
+	}
+	span.End()
+
+	o.duration.Record(ctx, float64(obs.Duration.Nanoseconds())/1e6, attrs...)
+	if obs.Hits > 0 {
+		o.hits.Add(ctx, int64(obs.Hits), o.attrs...)
+	}
+	if obs.Misses > 0 {
+		o.misses.Add(ctx, int64(obs.Misses), o.attrs...)
+	}
+	if obs.Bytes > 0 {
+		direction := "write"
+		if obs.Op.Reads() {
+			direction = "read"
+		}
+		o.bytes.Add(ctx, int64(obs.Bytes), o.with(attribute.String("gcache.direction", direction))...)
+	}
+	if obs.Errors > 0 {
+		o.errors.Add(ctx, int64(obs.Errors), attrs...)
+	}
+}
+
+// with returns the attributes of the cache along with the additional attributes.
+func (o *observer) with(kv ...attribute.KeyValue) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(o.attrs)+len(kv))
+	return append(append(attrs, o.attrs...), kv...)
+}
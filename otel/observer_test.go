@@ -0,0 +1,77 @@
+package otel
+
+import (
+	"context"
+	"github.com/amerkurev/gcache"
+	"github.com/amerkurev/gcache/store"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestObserver(t *testing.T) {
+	ctx := context.Background()
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	obs, err := NewObserver(Config{Cache: "users", Store: "map", TracerProvider: tp, MeterProvider: mp})
+	assert.Nil(t, err)
+
+	c := gcache.New[int, any](store.MapStore(0), gcache.WithObserver(obs))
+
+	// the cache span is a child of the request span
+	ctx, parent := tp.Tracer("test").Start(ctx, "request")
+	assert.Nil(t, c.SetWithContext(ctx, 1, "John"))
+	_, err = c.GetWithContext(ctx, 1)
+	assert.Nil(t, err)
+	_, err = c.GetWithContext(ctx, 2)
+	assert.NotNil(t, err)
+	assert.NotNil(t, c.SetWithContext(ctx, 3, func() {}))
+	parent.End()
+
+	ended := spans.Ended()
+	assert.Len(t, ended, 5)
+	assert.Equal(t, ended[0].Name(), "gcache.set")
+	assert.Equal(t, ended[0].Parent().SpanID(), parent.SpanContext().SpanID())
+	assert.Contains(t, ended[0].Attributes(), attribute.String("gcache.store", "map"))
+	assert.Contains(t, ended[0].Attributes(), attribute.Int("gcache.bytes", 5))
+	assert.Equal(t, ended[1].Name(), "gcache.get")
+	assert.Contains(t, ended[1].Attributes(), attribute.Bool("gcache.hit", true))
+	assert.Contains(t, ended[2].Attributes(), attribute.Bool("gcache.hit", false))
+	assert.Equal(t, ended[2].Status().Code, codes.Unset)
+	assert.Equal(t, ended[3].Status().Code, codes.Error)
+	assert.Len(t, ended[3].Events(), 1)
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(ctx, &rm))
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	sum := func(name string) (n int64) {
+		for _, dp := range metrics[name].(metricdata.Sum[int64]).DataPoints {
+			n += dp.Value
+		}
+		return n
+	}
+	assert.Equal(t, sum("gcache.hits"), int64(1))
+	assert.Equal(t, sum("gcache.misses"), int64(1))
+	assert.Equal(t, sum("gcache.bytes"), int64(10))
+	assert.Equal(t, sum("gcache.errors"), int64(1))
+
+	var count uint64
+	for _, dp := range metrics["gcache.operation.duration"].(metricdata.Histogram).DataPoints {
+		count += dp.Count
+	}
+	assert.Equal(t, count, uint64(4))
+}
@@ -0,0 +1,114 @@
+package prometheus
+
+import (
+	"context"
+	"github.com/amerkurev/gcache"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorConfig configures a collector.
+type CollectorConfig struct {
+	// Namespace prefixes the metric names, it is "gcache" by default.
+	Namespace string
+	// Buckets of the latency histograms in seconds, from 10µs to 2.6s by default.
+	Buckets []float64
+}
+
+// Collector collects the metrics of cache operations labelled by the cache name and the store type.
+// It has to be registered in a Prometheus registry and added to the caches by gcache.WithObserver.
+type Collector struct {
+	hits       *prom.CounterVec
+	misses     *prom.CounterVec
+	readBytes  *prom.CounterVec
+	writeBytes *prom.CounterVec
+	ops        *prom.CounterVec
+	errors     *prom.CounterVec
+	latency    *prom.HistogramVec
+}
+
+// NewCollector creates a collector of cache metrics.
+func NewCollector(cfg CollectorConfig) *Collector {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "gcache"
+	}
+	if cfg.Buckets == nil {
+		cfg.Buckets = prom.ExponentialBuckets(0.00001, 4, 10)
+	}
+
+	labels := []string{"cache", "store"}
+	opLabels := []string{"cache", "store", "op"}
+	counter := func(name, help string, labels []string) *prom.CounterVec {
+		return prom.NewCounterVec(prom.CounterOpts{Namespace: cfg.Namespace, Name: name, Help: help}, labels)
+	}
+
+	return &Collector{
+		hits:       counter("hits_total", "Number of keys found in the cache.", labels),
+		misses:     counter("misses_total", "Number of keys not found in the cache.", labels),
+		readBytes:  counter("read_bytes_total", "Number of bytes read from the store.", labels),
+		writeBytes: counter("write_bytes_total", "Number of bytes written to the store.", labels),
+		ops:        counter("operations_total", "Number of cache operations.", opLabels),
+		errors:     counter("errors_total", "Number of failed keys of cache operations.", opLabels),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: cfg.Namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of cache operations.",
+			Buckets:   cfg.Buckets,
+		}, opLabels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) collectors() []prom.Collector {
+	return []prom.Collector{c.hits, c.misses, c.readBytes, c.writeBytes, c.ops, c.errors, c.latency}
+}
+
+// Observer returns the observer of the cache with the name and the store type, e.g. "users" and "redis".
+func (c *Collector) Observer(cache, storeType string) gcache.Observer {
+	return &observer{c: c, cache: cache, store: storeType}
+}
+
+type observer struct {
+	c     *Collector
+	cache string
+	store string
+}
+
+func (o *observer) Start(ctx context.Context, _ gcache.Op) (context.Context, func(gcache.Observation)) {
+	return ctx, o.end
+}
+
+func (o *observer) end(obs gcache.Observation) {
+	c := o.c
+	op := string(obs.Op)
+	c.ops.WithLabelValues(o.cache, o.store, op).Inc()
+	c.latency.WithLabelValues(o.cache, o.store, op).Observe(obs.Duration.Seconds())
+	if obs.Errors > 0 {
+		c.errors.WithLabelValues(o.cache, o.store, op).Add(float64(obs.Errors))
+	}
+	if obs.Hits > 0 {
+		c.hits.WithLabelValues(o.cache, o.store).Add(float64(obs.Hits))
+	}
+	if obs.Misses > 0 {
+		c.misses.WithLabelValues(o.cache, o.store).Add(float64(obs.Misses))
+	}
+	if obs.Bytes > 0 {
+		if obs.Op.Reads() {
+			c.readBytes.WithLabelValues(o.cache, o.store).Add(float64(obs.Bytes))
+		} else {
+			c.writeBytes.WithLabelValues(o.cache, o.store).Add(float64(obs.Bytes))
+		}
+	}
+}
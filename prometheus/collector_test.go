@@ -0,0 +1,42 @@
+package prometheus
+
+import (
+	"context"
+	"github.com/amerkurev/gcache"
+	"github.com/amerkurev/gcache/store"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	col := NewCollector(CollectorConfig{})
+	reg := prom.NewPedanticRegistry()
+	assert.Nil(t, reg.Register(col))
+
+	c := gcache.New[int, string](store.MapStore(0), gcache.WithObserver(col.Observer("users", "map")))
+	assert.Nil(t, c.Set(1, "John"))
+	_, _ = c.Get(1)
+	_, _ = c.Get(2)
+	_ = c.GetMany(context.Background(), []int{1, 2, 3})
+	assert.Nil(t, c.Delete(1))
+
+	assert.Equal(t, testutil.ToFloat64(col.hits.WithLabelValues("users", "map")), 2.0)
+	assert.Equal(t, testutil.ToFloat64(col.misses.WithLabelValues("users", "map")), 3.0)
+	assert.Equal(t, testutil.ToFloat64(col.writeBytes.WithLabelValues("users", "map")), 5.0)
+	assert.Equal(t, testutil.ToFloat64(col.readBytes.WithLabelValues("users", "map")), 10.0)
+	assert.Equal(t, testutil.ToFloat64(col.ops.WithLabelValues("users", "map", "get")), 2.0)
+	assert.Equal(t, testutil.ToFloat64(col.ops.WithLabelValues("users", "map", "get_many")), 1.0)
+	assert.Equal(t, testutil.ToFloat64(col.ops.WithLabelValues("users", "map", "delete")), 1.0)
+	assert.Equal(t, testutil.CollectAndCount(col, "gcache_operation_duration_seconds"), 4)
+
+	// unsupported value
+	other := gcache.New[int, any](store.MapStore(0), gcache.WithObserver(col.Observer("other", "map")))
+	assert.NotNil(t, other.Set(1, func() {}))
+	assert.Equal(t, testutil.ToFloat64(col.errors.WithLabelValues("other", "map", "set")), 1.0)
+
+	n, err := testutil.GatherAndCount(reg)
+	assert.Nil(t, err)
+	assert.Greater(t, n, 0)
+}
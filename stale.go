@@ -0,0 +1,156 @@
+package gcache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// staleMagic starts the stale header. Msgpack never produces the first byte,
+// and the compression header never has the second one.
+var staleMagic = [2]byte{0xc1, 0x80}
+
+// staleRefreshRetry is the time after a failed refresh of a stale value before the key is refreshed again,
+// so an outage of the origin does not make every stale read call the loader.
+const staleRefreshRetry = 5 * time.Second
+
+// staleHeaderSize is the size of the magic, the soft expiration time and the time-to-live.
+const staleHeaderSize = 2 + 8 + 8
+
+// staleHeader is stored before the values when the stale values are served.
+// The store expires the entry after the grace period, the header tells when the value becomes stale.
+type staleHeader struct {
+	softExpireAt int64 // Unix nanoseconds
+	ttl          time.Duration
+}
+
+// stale reports whether the value is past its time-to-live.
+func (h staleHeader) stale() bool {
+	return h.softExpireAt != 0 && h.softExpireAt <= time.Now().UnixNano()
+}
+
+func putStaleHeader(b []byte, ttl time.Duration) {
+	var softExpireAt int64
+	if ttl > 0 {
+		softExpireAt = time.Now().Add(ttl).UnixNano()
+	}
+	copy(b, staleMagic[:])
+	binary.BigEndian.PutUint64(b[2:], uint64(softExpireAt))
+	binary.BigEndian.PutUint64(b[10:], uint64(ttl))
+}
+
+// splitStaleHeader returns the value without the header and the header, if there is one.
+func splitStaleHeader(b []byte) ([]byte, staleHeader) {
+	if len(b) < staleHeaderSize || b[0] != staleMagic[0] || b[1] != staleMagic[1] {
+		return b, staleHeader{}
+	}
+	h := staleHeader{
+		softExpireAt: int64(binary.BigEndian.Uint64(b[2:])),
+		ttl:          time.Duration(binary.BigEndian.Uint64(b[10:])),
+	}
+	return b[staleHeaderSize:], h
+}
+
+// withStaleHeader adds the header to the value and extends the time-to-live of the store entry by the grace period.
+// The values written without a time-to-live get the header too, so any value is told apart from the header.
+func (c *cache[K, V]) withStaleHeader(v []byte, ttl time.Duration) ([]byte, time.Duration) {
+	if c.staleGrace <= 0 {
+		return v, ttl
+	}
+	b := make([]byte, staleHeaderSize+len(v))
+	putStaleHeader(b, ttl)
+	copy(b[staleHeaderSize:], v)
+	if ttl <= 0 {
+		return b, ttl
+	}
+	return b, ttl + c.staleGrace
+}
+
+// splitStaleHeader returns the value without the header and the header, the values are read
+// without the header if the stale values are not served.
+func (c *cache[K, V]) splitStaleHeader(b []byte) ([]byte, staleHeader) {
+	if c.staleGrace <= 0 {
+		return b, staleHeader{}
+	}
+	return splitStaleHeader(b)
+}
+
+func (c *cache[K, V]) SetLoader(loader LoaderFunc[K, V]) {
+	c.loader.Store(loader)
+}
+
+func (c *cache[K, V]) registeredLoader() LoaderFunc[K, V] {
+	loader, _ := c.loader.Load().(LoaderFunc[K, V])
+	return loader
+}
+
+func (c *cache[K, V]) GetResult(ctx context.Context, key K) Result[K, V] {
+	var obs Observation
+	ctx = c.observe(ctx, OpGet, &obs)
+	defer c.done(&obs)
+
+	r := Result[K, V]{Key: key}
+	k, err := c.Hash(key)
+	obs.Hash += obs.lap()
+	if err != nil {
+		if c.Enabled() {
+			c.ErrRead()
+		}
+		obs.fail(err)
+		c.emitError(OpGet, key, "", err)
+		r.Err = err
+		return r
+	}
+
+	var h staleHeader
+	r.Value, h, r.Err = c.get(ctx, key, k, &obs)
+	r.Stale = c.revalidate(key, k, h, r.Err, c.registeredLoader())
+	return r
+}
+
+// revalidate starts refreshing the stale value by the loader, unless it is being refreshed already.
+// It reports whether the value is stale.
+func (c *cache[K, V]) revalidate(key K, k string, h staleHeader, err error, loader LoaderFunc[K, V]) bool {
+	if err != nil || !h.stale() {
+		return false
+	}
+	if loader == nil {
+		return true
+	}
+	if at, failed := c.refreshFailed.Load(k); failed && time.Since(at.(time.Time)) < c.refreshRetry {
+		return true
+	}
+	if _, refreshing := c.refreshing.LoadOrStore(k, struct{}{}); refreshing {
+		return true
+	}
+
+	go func() {
+		defer c.refreshing.Delete(k)
+		// the refresh is not bound to the context of the read that triggered it
+		ctx := context.Background()
+		v, err := loader(ctx, key)
+		if err != nil {
+			// the stale value is served until the grace period ends
+			c.refreshFailure(k)
+			c.emitError(OpGetOrLoad, key, k, err)
+			return
+		}
+		c.refreshFailed.Delete(k)
+		write := Observation{Op: OpGetOrLoad}
+		_ = c.set(ctx, key, k, v, setOptions{ttl: h.ttl}, &write)
+	}()
+	return true
+}
+
+// refreshFailure records the time of the failed refresh of the key and forgets the failures
+// older than the retry interval, so the keys that are not read again do not pile up.
+func (c *cache[K, V]) refreshFailure(k string) {
+	now := time.Now()
+	c.refreshFailed.Range(func(key, at any) bool {
+		if now.Sub(at.(time.Time)) >= c.refreshRetry {
+			c.refreshFailed.Delete(key)
+		}
+		return true
+	})
+	c.refreshFailed.Store(k, now)
+}
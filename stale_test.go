@@ -0,0 +1,178 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"github.com/amerkurev/gcache/compressor"
+	"github.com/amerkurev/gcache/store"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0), WithStale(time.Hour))
+
+	var calls int32
+	release := make(chan struct{})
+	c.SetLoader(func(_ context.Context, key int) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "fresh", nil
+	})
+
+	assert.Nil(t, c.SetWithTTL(1, "old", 10*time.Millisecond))
+	r := c.GetResult(ctx, 1)
+	assert.Equal(t, r, Result[int, string]{Key: 1, Value: "old"})
+
+	time.Sleep(20 * time.Millisecond)
+
+	// the stale value is served by every read while a single refresh is running
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := c.GetResult(ctx, 1)
+			assert.Nil(t, r.Err)
+			assert.Equal(t, r.Value, "old")
+			assert.True(t, r.Stale)
+		}()
+	}
+	wg.Wait()
+	v, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, v, "old")
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		r := c.GetResult(ctx, 1)
+		return r.Value == "fresh" && !r.Stale
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// the refreshed value keeps the time-to-live
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, c.GetResult(ctx, 1).Stale)
+}
+
+func TestCache_StaleIfError(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0), WithStale(50*time.Millisecond))
+
+	loadErr := errors.New("backend is down")
+	errs := make(chan error, 10)
+	c.OnError(func(e Event[int, string]) {
+		errs <- e.Err
+	})
+	c.SetLoader(func(context.Context, int) (string, error) {
+		return "", loadErr
+	})
+
+	assert.Nil(t, c.SetWithTTL(1, "old", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	r := c.GetResult(ctx, 1)
+	assert.Equal(t, r, Result[int, string]{Key: 1, Value: "old", Stale: true})
+	assert.Equal(t, <-errs, loadErr)
+
+	// the stale value is served until the grace period ends
+	r = c.GetResult(ctx, 1)
+	assert.True(t, r.Stale)
+	time.Sleep(50 * time.Millisecond)
+	r = c.GetResult(ctx, 1)
+	assert.True(t, errors.Is(r.Err, ErrNotFound))
+	assert.False(t, r.Stale)
+}
+
+func TestCache_StaleRefreshRetry(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0), WithStale(time.Hour))
+	c.(*cache[int, string]).refreshRetry = 50 * time.Millisecond
+
+	loadErr := errors.New("backend is down")
+	errs := make(chan error, 10)
+	c.OnError(func(e Event[int, string]) {
+		errs <- e.Err
+	})
+	var calls int32
+	c.SetLoader(func(context.Context, int) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", loadErr
+	})
+
+	assert.Nil(t, c.SetWithTTL(1, "old", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, c.GetResult(ctx, 1).Stale)
+	assert.Equal(t, <-errs, loadErr)
+
+	// the stale reads during the outage do not call the loader again
+	for i := 0; i < 10; i++ {
+		r := c.GetResult(ctx, 1)
+		assert.Equal(t, r, Result[int, string]{Key: 1, Value: "old", Stale: true})
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// the key is refreshed again after the retry interval
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, c.GetResult(ctx, 1).Stale)
+	assert.Equal(t, <-errs, loadErr)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestCache_StaleGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	c := New[int, string](store.MapStore(0), WithStale(time.Hour), WithCompression(&compressor.GzipCompressor{}, 0))
+
+	loaded := make(chan int, 10)
+	loader := func(_ context.Context, key int) (string, error) {
+		loaded <- key
+		return "loaded", nil
+	}
+
+	assert.Nil(t, c.SetWithTTL(1, "old", 10*time.Millisecond))
+	assert.Nil(t, c.Set(2, "forever"))
+	time.Sleep(20 * time.Millisecond)
+
+	// no loader is registered, so the stale value is not refreshed by reads
+	results := c.GetMany(ctx, []int{1, 2, 3})
+	assert.Equal(t, results[0], Result[int, string]{Key: 1, Value: "old", Stale: true})
+	assert.Equal(t, results[1], Result[int, string]{Key: 2, Value: "forever"})
+	assert.True(t, errors.Is(results[2].Err, ErrNotFound))
+
+	v, err := c.GetOrLoad(ctx, 1, loader, WithTTL(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, v, "old")
+	assert.Equal(t, <-loaded, 1)
+	assert.Eventually(t, func() bool {
+		v, _ := c.Get(1)
+		return v == "loaded"
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, loaded)
+}
+
+func TestCache_StaleHeader(t *testing.T) {
+	s := store.MapStore(0)
+	assert.Nil(t, New[int, string](s).Set(1, "plain"))
+
+	c := New[int, string](s, WithStale(time.Minute))
+	v, err := c.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, v, "plain")
+
+	b, h := splitStaleHeader([]byte{0xc1, 0x80, 1})
+	assert.Equal(t, b, []byte{0xc1, 0x80, 1})
+	assert.Equal(t, h, staleHeader{})
+
+	b = make([]byte, staleHeaderSize+1)
+	putStaleHeader(b, time.Minute)
+	b[staleHeaderSize] = 7
+	data, h := splitStaleHeader(b)
+	assert.Equal(t, data, []byte{7})
+	assert.Equal(t, h.ttl, time.Minute)
+	assert.False(t, h.stale())
+}
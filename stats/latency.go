@@ -0,0 +1,250 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// subBucketBits defines the precision of the histogram, every power of two range
+// is split into 16 buckets, so the percentiles are within 6% of the recorded durations.
+const (
+	subBucketBits = 4
+	subBuckets    = 1 << subBucketBits
+	// maxExponent limits the durations to 2^40ns (about 18 minutes), the longer durations are recorded as the limit.
+	maxExponent = 40 - subBucketBits
+	bucketCount = (maxExponent + 2) * subBuckets
+)
+
+// Latency summarizes the recorded durations of an operation or of its phase.
+type Latency struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// OpLatency is the latency of an operation and of its phases.
+type OpLatency struct {
+	Total   Latency
+	Hash    Latency
+	Marshal Latency // marshaling or unmarshaling along with the compression
+	Store   Latency
+}
+
+// LatencyStats is the latency of single key operations, the reads are also split by hits and misses.
+type LatencyStats struct {
+	Get     OpLatency
+	GetHit  OpLatency
+	GetMiss OpLatency
+	Set     OpLatency
+	Delete  OpLatency
+	Clear   OpLatency
+}
+
+// LatencyOp identifies the operation whose latency is recorded.
+type LatencyOp int
+
+const (
+	// LatencyGet is recorded for the failed reads, the latency of Get also includes the hits and misses.
+	LatencyGet LatencyOp = iota
+	LatencyGetHit
+	LatencyGetMiss
+	LatencySet
+	LatencyDelete
+	LatencyClear
+)
+
+// Phases are the durations of an operation and of its phases.
+type Phases struct {
+	Total   time.Duration
+	Hash    time.Duration
+	Marshal time.Duration
+	Store   time.Duration
+}
+
+// histogram counts durations in log-linear buckets like HDR histogram does. It is updated atomically.
+type histogram struct {
+	min     uint64
+	max     uint64
+	sum     uint64
+	buckets [bucketCount]uint32
+}
+
+func newHistogram() histogram {
+	return histogram{min: math.MaxUint64}
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := uint64(0)
+	if d > 0 {
+		v = uint64(d)
+	}
+	for m := atomic.LoadUint64(&h.min); v < m && !atomic.CompareAndSwapUint64(&h.min, m, v); {
+		m = atomic.LoadUint64(&h.min)
+	}
+	for m := atomic.LoadUint64(&h.max); v > m && !atomic.CompareAndSwapUint64(&h.max, m, v); {
+		m = atomic.LoadUint64(&h.max)
+	}
+	atomic.AddUint64(&h.sum, v)
+	atomic.AddUint32(&h.buckets[bucketOf(v)], 1)
+}
+
+func (h *histogram) reset() {
+	for i := range h.buckets {
+		atomic.StoreUint32(&h.buckets[i], 0)
+	}
+	atomic.StoreUint64(&h.sum, 0)
+	atomic.StoreUint64(&h.max, 0)
+	atomic.StoreUint64(&h.min, math.MaxUint64)
+}
+
+func (h *histogram) latency() Latency {
+	var snap histogramSnapshot
+	snap.add(h)
+	return snap.latency()
+}
+
+// histogramSnapshot sums the histograms read atomically.
+type histogramSnapshot struct {
+	buckets [bucketCount]uint32
+	count   int
+	min     uint64
+	max     uint64
+	sum     uint64
+}
+
+func (s *histogramSnapshot) add(h *histogram) {
+	count := 0
+	for i := range h.buckets {
+		c := atomic.LoadUint32(&h.buckets[i])
+		s.buckets[i] += c
+		count += int(c)
+	}
+	if count == 0 {
+		return
+	}
+
+	min, max := atomic.LoadUint64(&h.min), atomic.LoadUint64(&h.max)
+	if s.count == 0 || min < s.min {
+		s.min = min
+	}
+	if max > s.max {
+		s.max = max
+	}
+	s.count += count
+	s.sum += atomic.LoadUint64(&h.sum)
+}
+
+func (s *histogramSnapshot) latency() Latency {
+	if s.count == 0 {
+		return Latency{}
+	}
+	return Latency{
+		Count: s.count,
+		Min:   time.Duration(s.min),
+		Max:   time.Duration(s.max),
+		Mean:  time.Duration(s.sum / uint64(s.count)),
+		P50:   s.percentile(50),
+		P95:   s.percentile(95),
+		P99:   s.percentile(99),
+	}
+}
+
+// percentile returns the middle of the bucket of the percentile, limited by the recorded min and max.
+func (s *histogramSnapshot) percentile(p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(s.count)))
+	n := 0
+	for i, c := range s.buckets {
+		n += int(c)
+		if n >= rank {
+			if i == bucketCount-1 {
+				// the last bucket holds the durations above the limit
+				return time.Duration(s.max)
+			}
+			lo, hi := bucketRange(i)
+			v := lo + (hi-lo)/2
+			if v < s.min {
+				v = s.min
+			}
+			if v > s.max {
+				v = s.max
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(s.max)
+}
+
+func bucketOf(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - subBucketBits - 1
+	if exp > maxExponent {
+		return bucketCount - 1
+	}
+	return (exp+1)*subBuckets + int(v>>exp) - subBuckets
+}
+
+// bucketRange returns the lowest and the highest values of the bucket.
+func bucketRange(i int) (uint64, uint64) {
+	if i < subBuckets {
+		return uint64(i), uint64(i)
+	}
+	exp := i/subBuckets - 1
+	lo := uint64(i%subBuckets+subBuckets) << exp
+	return lo, lo + 1<<exp - 1
+}
+
+// opHistograms are allocated on the first recorded operation.
+type opHistograms struct {
+	total   histogram
+	hash    histogram
+	marshal histogram
+	store   histogram
+}
+
+func newOpHistograms() *opHistograms {
+	return &opHistograms{total: newHistogram(), hash: newHistogram(), marshal: newHistogram(), store: newHistogram()}
+}
+
+func (h *opHistograms) record(p Phases) {
+	h.total.record(p.Total)
+	h.hash.record(p.Hash)
+	h.marshal.record(p.Marshal)
+	h.store.record(p.Store)
+}
+
+func (h *opHistograms) reset() {
+	if h == nil {
+		return
+	}
+	h.total.reset()
+	h.hash.reset()
+	h.marshal.reset()
+	h.store.reset()
+}
+
+// mergeLatency returns the latency of the durations recorded by all histograms, the nil histograms are skipped.
+func mergeLatency(hs ...*opHistograms) OpLatency {
+	var total, hash, marshal, store histogramSnapshot
+	for _, h := range hs {
+		if h != nil {
+			total.add(&h.total)
+			hash.add(&h.hash)
+			marshal.add(&h.marshal)
+			store.add(&h.store)
+		}
+	}
+	return OpLatency{
+		Total:   total.latency(),
+		Hash:    hash.latency(),
+		Marshal: marshal.latency(),
+		Store:   store.latency(),
+	}
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	assert.Equal(t, h.latency(), Latency{})
+
+	for i := 1; i <= 1000; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+
+	l := h.latency()
+	assert.Equal(t, l.Count, 1000)
+	assert.Equal(t, l.Min, time.Microsecond)
+	assert.Equal(t, l.Max, time.Millisecond)
+	assert.Equal(t, l.Mean, 500500*time.Nanosecond)
+	assert.InEpsilon(t, 500*time.Microsecond, l.P50, 0.07)
+	assert.InEpsilon(t, 950*time.Microsecond, l.P95, 0.07)
+	assert.InEpsilon(t, 990*time.Microsecond, l.P99, 0.07)
+
+	// a single duration
+	h = newHistogram()
+	h.record(3 * time.Second)
+	l = h.latency()
+	assert.Equal(t, l.P50, 3*time.Second)
+	assert.Equal(t, l.P99, 3*time.Second)
+
+	// too long and negative durations
+	h = newHistogram()
+	h.record(time.Hour)
+	h.record(-time.Second)
+	l = h.latency()
+	assert.Equal(t, l.Min, time.Duration(0))
+	assert.Equal(t, l.Max, time.Hour)
+	assert.Equal(t, l.P99, time.Hour)
+}
+
+func TestBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 33, 1000, 123456789, 1<<40 - 1} {
+		i := bucketOf(v)
+		lo, hi := bucketRange(i)
+		assert.LessOrEqual(t, lo, v)
+		assert.GreaterOrEqual(t, hi, v)
+		assert.Less(t, i, bucketCount)
+	}
+	assert.Equal(t, bucketOf(1<<50), bucketCount-1)
+}
+
+func TestLatencyStats(t *testing.T) {
+	var s SyncStats
+	s.RecordLatency(LatencyGetHit, Phases{Total: 3 * time.Millisecond, Hash: time.Millisecond, Store: 2 * time.Millisecond})
+	s.RecordLatency(LatencyGetMiss, Phases{Total: time.Millisecond})
+	s.RecordLatency(LatencySet, Phases{Total: time.Millisecond})
+
+	l := s.Snapshot().Latency
+	assert.Equal(t, l.Get.Total.Count, 2)
+	assert.Equal(t, l.GetHit.Total.Count, 1)
+	assert.Equal(t, l.GetHit.Hash.Max, time.Millisecond)
+	assert.Equal(t, l.GetHit.Store.Max, 2*time.Millisecond)
+	assert.Equal(t, l.GetMiss.Total.Count, 1)
+	assert.Equal(t, l.Set.Total.Count, 1)
+	assert.Equal(t, l.Delete, OpLatency{})
+
+	s.Reset()
+	assert.Equal(t, s.Snapshot().Latency, LatencyStats{})
+}
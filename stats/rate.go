@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// rateResolution is the duration of a slot of the sliding windows.
+	rateResolution = 5 * time.Second
+	// rateSlots cover the longest window of 15 minutes.
+	rateSlots = int64(15 * time.Minute / rateResolution)
+)
+
+// Rates are the numbers of operations per second over the sliding windows of the last 1, 5 and 15 minutes.
+// An operation is a call of a cache method, e.g. GetMany is counted once. The windows are moved every 5 seconds.
+type Rates struct {
+	M1  float64
+	M5  float64
+	M15 float64
+}
+
+// window counts operations in the slots of the sliding windows. Every slot packs
+// the number of the time interval in the upper 32 bits and the operation count in the lower 32 bits,
+// so that a slot of an elapsed interval is reset atomically along with counting the first operation.
+type window struct {
+	slots [rateSlots]uint64
+}
+
+func epochOf(t time.Time) int64 {
+	return t.UnixNano() / int64(rateResolution)
+}
+
+// add counts n operations at the time.
+func (w *window) add(now time.Time, n int64) {
+	epoch := uint64(epochOf(now))
+	slot := &w.slots[epoch%uint64(rateSlots)]
+	for {
+		v := atomic.LoadUint64(slot)
+		next := epoch<<32 | uint64(n)
+		if v>>32 == epoch&(1<<32-1) {
+			next = v + uint64(n)
+		}
+		if atomic.CompareAndSwapUint64(slot, v, next) {
+			return
+		}
+	}
+}
+
+func (w *window) reset() {
+	for i := range w.slots {
+		atomic.StoreUint64(&w.slots[i], 0)
+	}
+}
+
+// count adds the operations of the complete intervals of every window before now to the counts.
+func (w *window) count(now time.Time, counts *[3]int64) {
+	epoch := epochOf(now)
+	for i := int64(1); i <= rateSlots; i++ {
+		e := uint64(epoch - i)
+		v := atomic.LoadUint64(&w.slots[e%uint64(rateSlots)])
+		if v>>32 != e&(1<<32-1) {
+			continue
+		}
+		n := int64(v & (1<<32 - 1))
+		for j, slots := range windowSlots {
+			if i <= slots {
+				counts[j] += n
+			}
+		}
+	}
+}
+
+// windowSlots are the numbers of slots of the 1, 5 and 15 minute windows.
+var windowSlots = [3]int64{
+	int64(time.Minute / rateResolution),
+	int64(5 * time.Minute / rateResolution),
+	rateSlots,
+}
+
+func rates(counts [3]int64) Rates {
+	perSecond := func(i int) float64 {
+		return float64(counts[i]) / (time.Duration(windowSlots[i]) * rateResolution).Seconds()
+	}
+	return Rates{M1: perSecond(0), M5: perSecond(1), M15: perSecond(2)}
+}
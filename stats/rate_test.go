@@ -0,0 +1,45 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	var w window
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 50; i++ {
+		w.add(now, 1)
+	}
+	w.add(now, 10)
+	var counts [3]int64
+	w.count(now, &counts)
+	assert.Equal(t, counts, [3]int64{}) // the current interval is not complete yet
+
+	counts = [3]int64{}
+	w.count(now.Add(rateResolution), &counts)
+	assert.Equal(t, counts, [3]int64{60, 60, 60})
+	assert.Equal(t, rates(counts), Rates{M1: 1, M5: 0.2, M15: 60.0 / 900})
+
+	counts = [3]int64{}
+	w.count(now.Add(2*time.Minute), &counts)
+	assert.Equal(t, counts, [3]int64{0, 60, 60})
+
+	counts = [3]int64{}
+	w.count(now.Add(15*time.Minute+rateResolution), &counts)
+	assert.Equal(t, counts, [3]int64{})
+
+	// the slot of an elapsed interval is reused
+	later := now.Add(15 * time.Minute)
+	w.add(later, 1)
+	counts = [3]int64{}
+	w.count(later.Add(rateResolution), &counts)
+	assert.Equal(t, counts, [3]int64{1, 1, 1})
+
+	w.reset()
+	counts = [3]int64{}
+	w.count(later.Add(rateResolution), &counts)
+	assert.Equal(t, counts, [3]int64{})
+}
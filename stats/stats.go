@@ -0,0 +1,344 @@
+package stats
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats collects significant metrics about cache operations.
+type Stats struct {
+	Hits           int
+	Miss           int
+	ReadBytes      int // bytes read from the store, compressed if the compression is enabled
+	WriteBytes     int // bytes written to the store, compressed if the compression is enabled
+	RawReadBytes   int // bytes read before decompression
+	RawWriteBytes  int // bytes written before compression
+	ReadCount      int
+	WriteCount     int
+	DeleteCount    int
+	ClearCount     int
+	ErrReadCount   int
+	ErrWriteCount  int
+	ErrDeleteCount int
+	ErrClearCount  int
+	Latency        LatencyStats
+	Rates          Rates
+
+	// Time is the time of the snapshot.
+	Time time.Time
+	// Interval is the time between the snapshots of a delta, it is zero for a snapshot.
+	Interval time.Duration
+}
+
+// Ops returns the number of operations, the batch operations count every key.
+func (s Stats) Ops() int {
+	return s.ReadCount + s.WriteCount + s.DeleteCount + s.ClearCount + s.Errors()
+}
+
+// Errors returns the number of failed operations.
+func (s Stats) Errors() int {
+	return s.ErrReadCount + s.ErrWriteCount + s.ErrDeleteCount + s.ErrClearCount
+}
+
+// HitRatio returns the ratio of hits to reads, or 0 if there were no reads.
+func (s Stats) HitRatio() float64 {
+	return ratio(s.Hits, s.Hits+s.Miss)
+}
+
+// ErrorRate returns the ratio of failed operations to all operations, or 0 if there were no operations.
+func (s Stats) ErrorRate() float64 {
+	return ratio(s.Errors(), s.Ops())
+}
+
+// AvgValueSize returns the average size of the values read from and written to the store.
+func (s Stats) AvgValueSize() float64 {
+	return ratio(s.ReadBytes+s.WriteBytes, s.Hits+s.WriteCount)
+}
+
+// Sub returns the delta of the counters since the previous snapshot, e.g. for a reporting period:
+//
+//	prev := cur
+//	cur, _ = c.Stats()
+//	d := cur.Sub(prev)
+//
+// Latency and Rates are not subtracted, they are the ones of s.
+func (s Stats) Sub(prev Stats) Stats {
+	return Stats{
+		Hits:           s.Hits - prev.Hits,
+		Miss:           s.Miss - prev.Miss,
+		ReadBytes:      s.ReadBytes - prev.ReadBytes,
+		WriteBytes:     s.WriteBytes - prev.WriteBytes,
+		RawReadBytes:   s.RawReadBytes - prev.RawReadBytes,
+		RawWriteBytes:  s.RawWriteBytes - prev.RawWriteBytes,
+		ReadCount:      s.ReadCount - prev.ReadCount,
+		WriteCount:     s.WriteCount - prev.WriteCount,
+		DeleteCount:    s.DeleteCount - prev.DeleteCount,
+		ClearCount:     s.ClearCount - prev.ClearCount,
+		ErrReadCount:   s.ErrReadCount - prev.ErrReadCount,
+		ErrWriteCount:  s.ErrWriteCount - prev.ErrWriteCount,
+		ErrDeleteCount: s.ErrDeleteCount - prev.ErrDeleteCount,
+		ErrClearCount:  s.ErrClearCount - prev.ErrClearCount,
+		Latency:        s.Latency,
+		Rates:          s.Rates,
+		Time:           s.Time,
+		Interval:       s.Time.Sub(prev.Time),
+	}
+}
+
+// OpsPerSecond returns the rate of operations over the interval of a delta, or 0 for a snapshot.
+func (s Stats) OpsPerSecond() float64 {
+	if s.Interval <= 0 {
+		return 0
+	}
+	return float64(s.Ops()) / s.Interval.Seconds()
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// shard holds the counters updated by a part of goroutines. It is padded to the size
+// of two cache lines, so that the shards updated by different CPUs do not share cache lines.
+type shard struct {
+	hits           int64
+	miss           int64
+	readBytes      int64
+	writeBytes     int64
+	rawReadBytes   int64
+	rawWriteBytes  int64
+	writeCount     int64
+	deleteCount    int64
+	clearCount     int64
+	errReadCount   int64
+	errWriteCount  int64
+	errDeleteCount int64
+	errClearCount  int64
+	pendingOps     int64 // operations not counted into the rates yet
+	_              [128 - 14*8]byte
+	ops            window
+	latency        [LatencyClear + 1]atomic.Value // *opHistograms, allocated on the first use
+	_              [128]byte
+}
+
+// opsPerClock is the number of operations of a shard counted into the rates at once,
+// so that the clock is not read for every operation.
+const opsPerClock = 16
+
+// addOps counts the pending operations into the rates at the time.
+func (sh *shard) addOps(now time.Time) {
+	if n := atomic.SwapInt64(&sh.pendingOps, 0); n > 0 {
+		sh.ops.add(now, n)
+	}
+}
+
+// histograms returns the histograms of the operation, they are allocated on the first use.
+func (sh *shard) histograms(op LatencyOp) *opHistograms {
+	if h := sh.recorded(op); h != nil {
+		return h
+	}
+	sh.latency[op].CompareAndSwap(nil, newOpHistograms())
+	return sh.recorded(op)
+}
+
+// recorded returns the histograms of the operation, or nil if the operation was never recorded.
+func (sh *shard) recorded(op LatencyOp) *opHistograms {
+	h, _ := sh.latency[op].Load().(*opHistograms)
+	return h
+}
+
+// shardToken remembers the shard of a goroutine for a while. The tokens are cached
+// by sync.Pool per processor, so goroutines running on different processors mostly use different shards.
+type shardToken struct {
+	idx uint32
+}
+
+var shardTokens = sync.Pool{
+	New: func() any {
+		return &shardToken{idx: rand.Uint32()}
+	},
+}
+
+// SyncStats implements concurrency-safe methods above Stats data fields. The counters are sharded and
+// updated atomically, so collecting the stats does not make goroutines wait for each other.
+// The zero value is ready to use.
+type SyncStats struct {
+	enabled int32
+
+	once   sync.Once
+	shards []shard
+	mask   uint32
+}
+
+// Enable turns on collecting the stats.
+func (s *SyncStats) Enable() {
+	atomic.StoreInt32(&s.enabled, 1)
+}
+
+// Enabled reports whether the stats are collected.
+func (s *SyncStats) Enabled() bool {
+	return atomic.LoadInt32(&s.enabled) == 1
+}
+
+// shard returns the shard of the calling goroutine.
+func (s *SyncStats) shard() *shard {
+	s.once.Do(func() {
+		n := 1
+		for n < runtime.GOMAXPROCS(0) {
+			n *= 2
+		}
+		s.shards = make([]shard, n)
+		s.mask = uint32(n - 1)
+	})
+
+	t := shardTokens.Get().(*shardToken)
+	sh := &s.shards[t.idx&s.mask]
+	shardTokens.Put(t)
+	return sh
+}
+
+// Snapshot returns copy of collected metrics. Every counter is read atomically, but the writes that happen
+// during the snapshot may be seen by some of the counters only. ReadCount is always Hits plus Miss.
+func (s *SyncStats) Snapshot() Stats {
+	st := Stats{Time: time.Now()}
+	var ops [3]int64
+	s.shard()
+	for i := range s.shards {
+		sh := &s.shards[i]
+		st.Hits += int(atomic.LoadInt64(&sh.hits))
+		st.Miss += int(atomic.LoadInt64(&sh.miss))
+		st.ReadBytes += int(atomic.LoadInt64(&sh.readBytes))
+		st.WriteBytes += int(atomic.LoadInt64(&sh.writeBytes))
+		st.RawReadBytes += int(atomic.LoadInt64(&sh.rawReadBytes))
+		st.RawWriteBytes += int(atomic.LoadInt64(&sh.rawWriteBytes))
+		st.WriteCount += int(atomic.LoadInt64(&sh.writeCount))
+		st.DeleteCount += int(atomic.LoadInt64(&sh.deleteCount))
+		st.ClearCount += int(atomic.LoadInt64(&sh.clearCount))
+		st.ErrReadCount += int(atomic.LoadInt64(&sh.errReadCount))
+		st.ErrWriteCount += int(atomic.LoadInt64(&sh.errWriteCount))
+		st.ErrDeleteCount += int(atomic.LoadInt64(&sh.errDeleteCount))
+		st.ErrClearCount += int(atomic.LoadInt64(&sh.errClearCount))
+		sh.addOps(st.Time)
+		sh.ops.count(st.Time, &ops)
+	}
+	st.ReadCount = st.Hits + st.Miss
+	st.Rates = rates(ops)
+
+	st.Latency = LatencyStats{
+		Get:     mergeLatency(s.recorded(LatencyGet, LatencyGetHit, LatencyGetMiss)...),
+		GetHit:  mergeLatency(s.recorded(LatencyGetHit)...),
+		GetMiss: mergeLatency(s.recorded(LatencyGetMiss)...),
+		Set:     mergeLatency(s.recorded(LatencySet)...),
+		Delete:  mergeLatency(s.recorded(LatencyDelete)...),
+		Clear:   mergeLatency(s.recorded(LatencyClear)...),
+	}
+	return st
+}
+
+// Reset sets all metric fields to zero-value.
+func (s *SyncStats) Reset() {
+	s.shard()
+	for i := range s.shards {
+		sh := &s.shards[i]
+		atomic.StoreInt64(&sh.hits, 0)
+		atomic.StoreInt64(&sh.miss, 0)
+		atomic.StoreInt64(&sh.readBytes, 0)
+		atomic.StoreInt64(&sh.writeBytes, 0)
+		atomic.StoreInt64(&sh.rawReadBytes, 0)
+		atomic.StoreInt64(&sh.rawWriteBytes, 0)
+		atomic.StoreInt64(&sh.writeCount, 0)
+		atomic.StoreInt64(&sh.deleteCount, 0)
+		atomic.StoreInt64(&sh.clearCount, 0)
+		atomic.StoreInt64(&sh.errReadCount, 0)
+		atomic.StoreInt64(&sh.errWriteCount, 0)
+		atomic.StoreInt64(&sh.errDeleteCount, 0)
+		atomic.StoreInt64(&sh.errClearCount, 0)
+		atomic.StoreInt64(&sh.pendingOps, 0)
+		sh.ops.reset()
+		for op := range sh.latency {
+			sh.recorded(LatencyOp(op)).reset()
+		}
+	}
+}
+
+// IncRead increments metrics of read operation, n is the size of the stored data and raw is its uncompressed size.
+func (s *SyncStats) IncRead(hits bool, n, raw int) {
+	sh := s.shard()
+	if hits {
+		atomic.AddInt64(&sh.hits, 1)
+		atomic.AddInt64(&sh.readBytes, int64(n))
+		atomic.AddInt64(&sh.rawReadBytes, int64(raw))
+	} else {
+		atomic.AddInt64(&sh.miss, 1)
+	}
+}
+
+// IncWrite increments metrics of write operation, n is the size of the stored data and raw is its uncompressed size.
+func (s *SyncStats) IncWrite(n, raw int) {
+	sh := s.shard()
+	atomic.AddInt64(&sh.writeCount, 1)
+	atomic.AddInt64(&sh.writeBytes, int64(n))
+	atomic.AddInt64(&sh.rawWriteBytes, int64(raw))
+}
+
+// IncDelete increments metrics of delete operation.
+func (s *SyncStats) IncDelete() {
+	atomic.AddInt64(&s.shard().deleteCount, 1)
+}
+
+// IncClear increments metrics of clear operation.
+func (s *SyncStats) IncClear() {
+	atomic.AddInt64(&s.shard().clearCount, 1)
+}
+
+// ErrRead increments the read error counter.
+func (s *SyncStats) ErrRead() {
+	atomic.AddInt64(&s.shard().errReadCount, 1)
+}
+
+// ErrWrite increments the write error counter.
+func (s *SyncStats) ErrWrite() {
+	atomic.AddInt64(&s.shard().errWriteCount, 1)
+}
+
+// ErrDelete increments the delete error counter.
+func (s *SyncStats) ErrDelete() {
+	atomic.AddInt64(&s.shard().errDeleteCount, 1)
+}
+
+// ErrClear increments the clear error counter.
+func (s *SyncStats) ErrClear() {
+	atomic.AddInt64(&s.shard().errClearCount, 1)
+}
+
+// RecordOp counts an operation into the rates. The operations of a shard are counted at the time
+// of every 16th of them and of the snapshot, so the clock is not read for every operation.
+func (s *SyncStats) RecordOp() {
+	sh := s.shard()
+	if atomic.AddInt64(&sh.pendingOps, 1) >= opsPerClock {
+		sh.addOps(time.Now())
+	}
+}
+
+// RecordLatency records the durations of the operation and of its phases.
+// The histograms are sharded like the counters and merged by the snapshot.
+func (s *SyncStats) RecordLatency(op LatencyOp, p Phases) {
+	s.shard().histograms(op).record(p)
+}
+
+// recorded returns the histograms of the operations in every shard, the nil histograms were never recorded.
+func (s *SyncStats) recorded(ops ...LatencyOp) []*opHistograms {
+	s.shard()
+	hs := make([]*opHistograms, 0, len(ops)*len(s.shards))
+	for i := range s.shards {
+		for _, op := range ops {
+			hs = append(hs, s.shards[i].recorded(op))
+		}
+	}
+	return hs
+}
@@ -0,0 +1,170 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	var s SyncStats
+	s.IncRead(false, 0, 0)
+	s.Reset()
+	assert.Equal(t, s.Snapshot().Miss, 0)
+
+	s.IncRead(true, 100, 200)
+	s.IncRead(false, 100, 100)
+	s.IncWrite(1000, 2000)
+	s.IncWrite(100, 100)
+	s.IncDelete()
+	s.IncClear()
+	s.ErrRead()
+	s.ErrWrite()
+	s.ErrDelete()
+	s.ErrClear()
+
+	st := s.Snapshot()
+	assert.Equal(t, st.Miss, 1)
+	assert.Equal(t, st.Hits, 1)
+	assert.Equal(t, st.ReadBytes, 100)
+	assert.Equal(t, st.WriteBytes, 1100)
+	assert.Equal(t, st.RawReadBytes, 200)
+	assert.Equal(t, st.RawWriteBytes, 2100)
+	assert.Equal(t, st.ReadCount, 2)
+	assert.Equal(t, st.WriteCount, 2)
+	assert.Equal(t, st.DeleteCount, 1)
+	assert.Equal(t, st.ErrReadCount, 1)
+	assert.Equal(t, st.ErrWriteCount, 1)
+	assert.Equal(t, st.ErrDeleteCount, 1)
+	assert.Equal(t, st.ErrDeleteCount, 1)
+}
+
+func TestStats_Concurrency(t *testing.T) {
+	var s SyncStats
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 1000; k++ {
+				s.IncRead(k%2 == 0, 10, 20)
+				s.IncWrite(10, 20)
+				s.RecordLatency(LatencySet, Phases{Total: time.Microsecond})
+				_ = s.Snapshot()
+			}
+		}()
+	}
+	s.Enable()
+	wg.Wait()
+
+	st := s.Snapshot()
+	assert.True(t, s.Enabled())
+	assert.Equal(t, st.Hits, 5000)
+	assert.Equal(t, st.Miss, 5000)
+	assert.Equal(t, st.ReadBytes, 50000)
+	assert.Equal(t, st.RawWriteBytes, 200000)
+	assert.Equal(t, st.Latency.Set.Total.Count, 10000)
+}
+
+func BenchmarkSyncStats(b *testing.B) {
+	var s SyncStats
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.IncRead(true, 100, 100)
+		}
+	})
+}
+
+func TestStats_Derived(t *testing.T) {
+	var s SyncStats
+	st := s.Snapshot()
+	assert.Equal(t, st.HitRatio(), 0.0)
+	assert.Equal(t, st.ErrorRate(), 0.0)
+	assert.Equal(t, st.AvgValueSize(), 0.0)
+	assert.Equal(t, st.OpsPerSecond(), 0.0)
+
+	s.IncRead(true, 100, 100)
+	s.IncRead(true, 200, 200)
+	s.IncRead(true, 300, 300)
+	s.IncRead(false, 0, 0)
+	s.IncWrite(400, 400)
+	s.ErrWrite()
+	st = s.Snapshot()
+	assert.Equal(t, st.Ops(), 6)
+	assert.Equal(t, st.Errors(), 1)
+	assert.Equal(t, st.HitRatio(), 0.75)
+	assert.Equal(t, st.ErrorRate(), 1.0/6)
+	assert.Equal(t, st.AvgValueSize(), 250.0)
+}
+
+func TestStats_Sub(t *testing.T) {
+	var s SyncStats
+	s.IncRead(true, 100, 100)
+	s.IncWrite(100, 100)
+	prev := s.Snapshot()
+
+	s.IncRead(false, 0, 0)
+	s.IncRead(true, 10, 20)
+	s.IncDelete()
+	s.ErrClear()
+	cur := s.Snapshot()
+	cur.Time = prev.Time.Add(2 * time.Second)
+
+	d := cur.Sub(prev)
+	assert.Equal(t, d.Hits, 1)
+	assert.Equal(t, d.Miss, 1)
+	assert.Equal(t, d.ReadCount, 2)
+	assert.Equal(t, d.ReadBytes, 10)
+	assert.Equal(t, d.RawReadBytes, 20)
+	assert.Equal(t, d.WriteCount, 0)
+	assert.Equal(t, d.DeleteCount, 1)
+	assert.Equal(t, d.ErrClearCount, 1)
+	assert.Equal(t, d.Interval, 2*time.Second)
+	assert.Equal(t, d.HitRatio(), 0.5)
+	assert.Equal(t, d.OpsPerSecond(), 2.0)
+
+	// the snapshots are not affected
+	assert.Equal(t, cur.Hits, 2)
+	assert.Equal(t, prev.Hits, 1)
+}
+
+func TestStats_Rates(t *testing.T) {
+	var s SyncStats
+	past := time.Now().Add(-30 * time.Second)
+	s.shard().ops.add(past, 120)
+	st := s.Snapshot()
+	assert.Equal(t, st.Rates, Rates{M1: 2, M5: 0.4, M15: 120.0 / 900})
+
+	s.Reset()
+	assert.Equal(t, s.Snapshot().Rates, Rates{})
+
+	// the operations are counted at the time of every 16th of them and of the snapshot
+	for i := 0; i < 100; i++ {
+		s.RecordOp()
+	}
+	var pending int64
+	for i := range s.shards {
+		pending += s.shards[i].pendingOps
+	}
+	assert.Less(t, pending, int64(100))
+	st = s.Snapshot()
+	var counts [3]int64
+	for i := range s.shards {
+		s.shards[i].ops.count(st.Time.Add(2*rateResolution), &counts)
+	}
+	assert.Equal(t, counts, [3]int64{100, 100, 100})
+}
+
+func TestStats_LatencyShards(t *testing.T) {
+	var s SyncStats
+	s.shard()
+	// the histograms of every shard are merged
+	for i := range s.shards {
+		s.shards[i].histograms(LatencySet).record(Phases{Total: time.Duration(i+1) * time.Millisecond})
+	}
+	l := s.Snapshot().Latency.Set.Total
+	assert.Equal(t, l.Count, len(s.shards))
+	assert.Equal(t, l.Min, time.Millisecond)
+	assert.Equal(t, l.Max, time.Duration(len(s.shards))*time.Millisecond)
+}
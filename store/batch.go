@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is the data to be set into the store by SetMany.
+type Entry struct {
+	Key  string
+	Data []byte
+	TTL  time.Duration
+}
+
+// BatchStore is the interface implemented by stores that can process many keys at once.
+// The errors are returned for every key in the order of keys.
+type BatchStore interface {
+	Store
+	GetMany(ctx context.Context, keys []string) ([][]byte, []error)
+	SetMany(ctx context.Context, entries []Entry) []error
+	DeleteMany(ctx context.Context, keys []string) []error
+}
+
+// GetMany gets the data of many keys from the store. The missing keys have ErrNotFound error.
+// The stores that do not implement BatchStore are requested for every key separately.
+func GetMany(ctx context.Context, s Store, keys []string) ([][]byte, []error) {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.GetMany(ctx, keys)
+	}
+
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		data[i], errs[i] = s.Get(ctx, key)
+	}
+	return data, errs
+}
+
+// SetMany sets many entries into the store.
+// The stores that do not implement BatchStore are requested for every entry separately.
+func SetMany(ctx context.Context, s Store, entries []Entry) []error {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.SetMany(ctx, entries)
+	}
+
+	errs := make([]error, len(entries))
+	for i, e := range entries {
+		errs[i] = SetWithTTL(ctx, s, e.Key, e.Data, e.TTL)
+	}
+	return errs
+}
+
+// DeleteMany deletes many keys from the store.
+// The stores that do not implement BatchStore are requested for every key separately.
+func DeleteMany(ctx context.Context, s Store, keys []string) []error {
+	if bs, ok := s.(BatchStore); ok {
+		return bs.DeleteMany(ctx, keys)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = s.Delete(ctx, key)
+	}
+	return errs
+}
+
+// repeatErr returns n copies of the error, it is used when the whole batch fails.
+func repeatErr(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// firstErr returns the first non-nil error.
+func firstErr(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
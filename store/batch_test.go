@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/allegro/bigcache/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testBatchStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	n := 1200 // more than a single SQLite query can take
+
+	entries := make([]Entry, n)
+	keys := make([]string, n+1)
+	for i := range entries {
+		entries[i] = Entry{Key: fmt.Sprintf("key%d", i), Data: []byte{byte(i)}}
+		keys[i] = entries[i].Key
+	}
+	keys[n] = "missing"
+	entries[0].TTL = time.Minute
+
+	errs := SetMany(ctx, s, entries)
+	assert.Len(t, errs, n)
+	assert.Nil(t, firstErr(errs))
+
+	data, errs := GetMany(ctx, s, keys)
+	assert.Len(t, data, n+1)
+	assert.Len(t, errs, n+1)
+	for i := 0; i < n; i++ {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, data[i], []byte{byte(i)})
+	}
+	assert.Nil(t, data[n])
+	assert.True(t, errors.Is(errs[n], ErrNotFound))
+
+	// repeated delete must be safe
+	errs = DeleteMany(ctx, s, keys[:n/2])
+	assert.Nil(t, firstErr(errs))
+	errs = DeleteMany(ctx, s, keys[:n/2])
+	assert.Nil(t, firstErr(errs))
+
+	_, errs = GetMany(ctx, s, keys)
+	for i := 0; i < n; i++ {
+		if i < n/2 {
+			assert.True(t, errors.Is(errs[i], ErrNotFound))
+		} else {
+			assert.Nil(t, errs[i])
+		}
+	}
+
+	// empty batches are safe
+	data, errs = GetMany(ctx, s, nil)
+	assert.Empty(t, data)
+	assert.Empty(t, errs)
+	assert.Empty(t, SetMany(ctx, s, nil))
+	assert.Empty(t, DeleteMany(ctx, s, nil))
+}
+
+func TestBatch_MapStore(t *testing.T) {
+	testBatchStore(t, MapStore(0))
+}
+
+func TestBatch_BoundedStore(t *testing.T) {
+	testBatchStore(t, BoundedStore(BoundedConfig{MaxEntries: 10_000}))
+}
+
+func TestBatch_BigcacheStore(t *testing.T) {
+	bc, err := bigcache.NewBigCache(bigcache.DefaultConfig(10 * time.Minute))
+	assert.Nil(t, err)
+	testBatchStore(t, BigcacheStore(bc))
+}
+
+func TestBatch_RedisStore(t *testing.T) {
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	testBatchStore(t, RedisStore(rdb))
+}
+
+func TestBatch_SQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	testBatchStore(t, s)
+}
+
+func TestBatch_TieredStore(t *testing.T) {
+	testBatchStore(t, Tiered(MapStore(0), MapStore(0)))
+}
+
+func TestBatch_TieredStoreBackfill(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := MapStore(0), MapStore(0)
+	s := TieredStore(TieredConfig{
+		Tiers: []Tier{{Store: l1}, {Store: unavailableStore{}, OnError: IgnoreErrors}, {Store: l2}},
+	})
+
+	assert.Nil(t, l1.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, l2.Set(ctx, "b", []byte{2}))
+
+	data, errs := GetMany(ctx, s, []string{"a", "b", "c"})
+	assert.Equal(t, data, [][]byte{{1}, {2}, nil})
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.True(t, errors.Is(errs[2], ErrNotFound))
+
+	b, err := l1.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+
+	s = Tiered(l1, unavailableStore{})
+	_, errs = GetMany(ctx, s, []string{"a", "c"})
+	assert.Nil(t, errs[0])
+	assert.True(t, errors.Is(errs[1], errUnavailable))
+	errs = SetMany(ctx, s, []Entry{{Key: "a"}})
+	assert.True(t, errors.Is(errs[0], errUnavailable))
+	errs = DeleteMany(ctx, s, []string{"a"})
+	assert.True(t, errors.Is(errs[0], errUnavailable))
+}
@@ -2,10 +2,17 @@ package store
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"github.com/allegro/bigcache/v3"
+	"strings"
+	"time"
 )
 
+// bigcacheHeaderSize is the size of expiration time stored before every entry,
+// because Bigcache supports only a global life window.
+const bigcacheHeaderSize = 8
+
 type bigcacheStore struct {
 	bc *bigcache.BigCache
 }
@@ -21,12 +28,27 @@ func (b *bigcacheStore) Get(_ context.Context, key string) ([]byte, error) {
 		if errors.Is(err, bigcache.ErrEntryNotFound) {
 			return nil, ErrNotFound
 		}
+		return nil, err
+	}
+
+	if len(v) < bigcacheHeaderSize {
+		return nil, ErrNotFound
+	}
+	if expired(int64(binary.BigEndian.Uint64(v))) {
+		return nil, ErrNotFound
 	}
-	return v, err
+	return v[bigcacheHeaderSize:], nil
 }
 
-func (b *bigcacheStore) Set(_ context.Context, key string, data []byte) error {
-	return b.bc.Set(key, data)
+func (b *bigcacheStore) Set(ctx context.Context, key string, data []byte) error {
+	return b.SetWithTTL(ctx, key, data, 0)
+}
+
+func (b *bigcacheStore) SetWithTTL(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	v := make([]byte, bigcacheHeaderSize+len(data))
+	binary.BigEndian.PutUint64(v, uint64(expireAt(ttl)))
+	copy(v[bigcacheHeaderSize:], data)
+	return b.bc.Set(key, v)
 }
 
 func (b *bigcacheStore) Delete(_ context.Context, key string) error {
@@ -43,3 +65,23 @@ func (b *bigcacheStore) Delete(_ context.Context, key string) error {
 func (b *bigcacheStore) Clear(_ context.Context) error {
 	return b.bc.Reset()
 }
+
+func (b *bigcacheStore) Namespace(name string) Store {
+	return newPrefixStore(b, name, b.clearPrefix)
+}
+
+func (b *bigcacheStore) clearPrefix(ctx context.Context, prefix string) error {
+	it := b.bc.Iterator()
+	for it.SetNext() {
+		e, err := it.Value()
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(e.Key(), prefix) {
+			if err = b.Delete(ctx, e.Key()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -45,3 +45,29 @@ func TestBigcacheStore(t *testing.T) {
 	assert.Nil(t, b)
 	assert.True(t, errors.Is(err, ErrNotFound))
 }
+
+func TestBigcacheStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	store, err := bigcache.NewBigCache(bigcache.DefaultConfig(10 * time.Minute))
+	assert.Nil(t, err)
+	s := BigcacheStore(store).(TTLStore)
+
+	err = s.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
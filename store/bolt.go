@@ -0,0 +1,453 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"go.etcd.io/bbolt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// boltEntries maps the keys to the entries: the expiration time, the write sequence and the data.
+	boltEntries = []byte("gcache")
+	// boltOrder maps the write sequence to the keys, the entries written earliest are evicted first.
+	boltOrder = []byte("gcache_order")
+
+	// boltOpen opens the database file of the store, it is replaced by the tests.
+	boltOpen = bbolt.Open
+)
+
+// boltHeaderSize is the size of the expiration time and the write sequence stored before the data.
+const boltHeaderSize = 8 + 8
+
+// BoltConfig configures a bbolt store.
+type BoltConfig struct {
+	// MaxEntries limits the number of entries. Zero means no limit.
+	MaxEntries int
+	// MaxBytes limits the total size of keys and data. Zero means no limit.
+	MaxBytes int
+	// CleanupInterval is the period of deleting the expired entries, which are never read but take space.
+	// The expired entries are not deleted in the background if it is zero.
+	CleanupInterval time.Duration
+	// OnError is called for the errors of the background cleanup.
+	OnError func(err error)
+	// Options are passed to bbolt, the database file is not waited for longer than a second by default.
+	Options *bbolt.Options
+}
+
+// Bolt is a store persisted by bbolt, an embedded key-value database written in pure Go.
+type Bolt interface {
+	TTLStore
+	// Len returns the number of entries, including the expired entries not deleted yet.
+	Len() int
+	// Size returns the total size of keys and data.
+	Size() int
+	// DeleteExpired deletes the expired entries and returns the number of deleted entries.
+	DeleteExpired(ctx context.Context) (int, error)
+	// Compact rewrites the database file, so the space of the deleted entries is returned to the file system.
+	// The operations wait for the compaction.
+	Compact(ctx context.Context) error
+	// Close stops the background cleanup and closes the database.
+	Close() error
+}
+
+type boltStore struct {
+	path string
+	opts *bbolt.Options
+	cfg  BoltConfig
+
+	// mx is locked exclusively by Compact and Close, which replace the database.
+	mx sync.RWMutex
+	db *bbolt.DB
+	// wmx serializes the writes along with the updates of the counters.
+	wmx  sync.Mutex
+	len  int64
+	size int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// BoltStore opens or creates the bbolt database file and creates a store in it. The entries written earliest
+// are evicted when the store exceeds the configured limits.
+func BoltStore(path string, cfg BoltConfig) (Bolt, error) {
+	opts := cfg.Options
+	if opts == nil {
+		opts = &bbolt.Options{Timeout: time.Second}
+	}
+	s := &boltStore{path: path, opts: opts, cfg: cfg, done: make(chan struct{})}
+	// the original database is restored if a compaction was interrupted before the compacted one replaced it
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		_ = os.Rename(path+".orig", path)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if cfg.CleanupInterval > 0 {
+		s.wg.Add(1)
+		go s.cleanup()
+	}
+	return s, nil
+}
+
+// open opens the database, creates the buckets and counts the entries.
+func (s *boltStore) open() error {
+	db, err := boltOpen(s.path, 0o600, s.opts)
+	if err != nil {
+		return err
+	}
+
+	var n, size int64
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltOrder); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucketIfNotExists(boltEntries)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			n++
+			size += int64(len(k) + len(v) - boltHeaderSize)
+			return nil
+		})
+	})
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+
+	s.db = db
+	atomic.StoreInt64(&s.len, n)
+	atomic.StoreInt64(&s.size, size)
+	return nil
+}
+
+func (s *boltStore) Get(_ context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := s.view(func(tx *bbolt.Tx) error {
+		var err error
+		data, err = boltGet(tx, key)
+		return err
+	})
+	return data, err
+}
+
+// boltGet returns a copy of the data, the data of bbolt is valid only during the transaction.
+func boltGet(tx *bbolt.Tx, key string) ([]byte, error) {
+	v := tx.Bucket(boltEntries).Get([]byte(key))
+	if v == nil || expired(int64(binary.BigEndian.Uint64(v))) {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v[boltHeaderSize:]...), nil
+}
+
+func (s *boltStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
+}
+
+func (s *boltStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return s.SetMany(ctx, []Entry{{Key: key, Data: data, TTL: ttl}})[0]
+}
+
+func (s *boltStore) Delete(ctx context.Context, key string) error {
+	return s.DeleteMany(ctx, []string{key})[0]
+}
+
+func (s *boltStore) Clear(context.Context) error {
+	return s.update(func(tx *bbolt.Tx, c *boltCounts) error {
+		for _, name := range [][]byte{boltEntries, boltOrder} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		c.len, c.size = 0, 0
+		return nil
+	})
+}
+
+func (s *boltStore) Len() int {
+	return int(atomic.LoadInt64(&s.len))
+}
+
+func (s *boltStore) Size() int {
+	return int(atomic.LoadInt64(&s.size))
+}
+
+func (s *boltStore) GetMany(_ context.Context, keys []string) ([][]byte, []error) {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	err := s.view(func(tx *bbolt.Tx) error {
+		for i, key := range keys {
+			data[i], errs[i] = boltGet(tx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return data, repeatErr(err, len(keys))
+	}
+	return data, errs
+}
+
+// SetMany writes the entries in a single transaction.
+func (s *boltStore) SetMany(_ context.Context, entries []Entry) []error {
+	errs := make([]error, len(entries))
+	err := s.update(func(tx *bbolt.Tx, c *boltCounts) error {
+		b, order := tx.Bucket(boltEntries), tx.Bucket(boltOrder)
+		for i, e := range entries {
+			if s.cfg.MaxBytes > 0 && entrySize(e.Key, e.Data) > s.cfg.MaxBytes {
+				errs[i] = ErrEntryTooLarge
+				continue
+			}
+			if err := c.remove(b, order, []byte(e.Key)); err != nil {
+				return err
+			}
+
+			seq, err := order.NextSequence()
+			if err != nil {
+				return err
+			}
+			v := make([]byte, boltHeaderSize+len(e.Data))
+			binary.BigEndian.PutUint64(v, uint64(expireAt(e.TTL)))
+			binary.BigEndian.PutUint64(v[8:], seq)
+			copy(v[boltHeaderSize:], e.Data)
+
+			if err = order.Put(v[8:boltHeaderSize], []byte(e.Key)); err != nil {
+				return err
+			}
+			if err = b.Put([]byte(e.Key), v); err != nil {
+				return err
+			}
+			c.len++
+			c.size += int64(entrySize(e.Key, e.Data))
+			if err = s.evict(b, order, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return repeatErr(err, len(entries))
+	}
+	return errs
+}
+
+// DeleteMany deletes the keys in a single transaction, the missing keys are not an error.
+func (s *boltStore) DeleteMany(_ context.Context, keys []string) []error {
+	err := s.update(func(tx *bbolt.Tx, c *boltCounts) error {
+		b, order := tx.Bucket(boltEntries), tx.Bucket(boltOrder)
+		for _, key := range keys {
+			if err := c.remove(b, order, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return repeatErr(err, len(keys))
+}
+
+func (s *boltStore) Namespace(name string) Store {
+	return newPrefixStore(s, name, s.clearPrefix)
+}
+
+func (s *boltStore) clearPrefix(_ context.Context, prefix string) error {
+	return s.update(func(tx *bbolt.Tx, c *boltCounts) error {
+		b, order := tx.Bucket(boltEntries), tx.Bucket(boltOrder)
+		// the keys are collected first, the cursor skips an entry after the deletion
+		var keys [][]byte
+		cur := b.Cursor()
+		for k, _ := cur.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cur.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for _, k := range keys {
+			if err := c.remove(b, order, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) DeleteExpired(context.Context) (int, error) {
+	var n int
+	err := s.update(func(tx *bbolt.Tx, c *boltCounts) error {
+		b, order := tx.Bucket(boltEntries), tx.Bucket(boltOrder)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if expired(int64(binary.BigEndian.Uint64(v))) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err = c.remove(b, order, k); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+func (s *boltStore) Compact(context.Context) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+
+	// a file left by an interrupted compaction is not reused
+	tmp := s.path + ".compact"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	dst, err := bbolt.Open(tmp, 0o600, s.opts)
+	if err != nil {
+		return err
+	}
+	if err = bbolt.Compact(dst, s.db, 0); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err = s.db.Close(); err != nil {
+		return err
+	}
+	s.db = nil
+
+	// the original database is kept until the compacted one is opened in its place
+	orig := s.path + ".orig"
+	if err = os.Rename(s.path, orig); err != nil {
+		_ = os.Remove(tmp)
+		if openErr := s.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err == nil {
+		if err = s.open(); err == nil {
+			_ = os.Remove(orig)
+			return nil
+		}
+	}
+
+	// the original database is opened again if the compacted one cannot replace it
+	_ = os.Remove(tmp)
+	if renameErr := os.Rename(orig, s.path); renameErr != nil {
+		return renameErr
+	}
+	if openErr := s.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func (s *boltStore) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		if s.db != nil {
+			err = s.db.Close()
+		}
+	})
+	return err
+}
+
+func (s *boltStore) cleanup() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if _, err := s.DeleteExpired(context.Background()); err != nil && s.cfg.OnError != nil {
+				s.cfg.OnError(err)
+			}
+		}
+	}
+}
+
+// boltCounts are the counters changed by a transaction, they are stored when it is committed.
+type boltCounts struct {
+	len  int64
+	size int64
+}
+
+// remove deletes the entry of the key if there is one.
+func (c *boltCounts) remove(b, order *bbolt.Bucket, key []byte) error {
+	v := b.Get(key)
+	if v == nil {
+		return nil
+	}
+	c.len--
+	c.size -= int64(len(key) + len(v) - boltHeaderSize)
+	if err := order.Delete(v[8:boltHeaderSize]); err != nil {
+		return err
+	}
+	return b.Delete(key)
+}
+
+// evict deletes the entries written earliest until the store fits the limits, the entry written last is kept.
+func (s *boltStore) evict(b, order *bbolt.Bucket, c *boltCounts) error {
+	for c.len > 1 && (s.cfg.MaxEntries > 0 && c.len > int64(s.cfg.MaxEntries) ||
+		s.cfg.MaxBytes > 0 && c.size > int64(s.cfg.MaxBytes)) {
+		_, key := order.Cursor().First()
+		if err := c.remove(b, order, append([]byte{}, key...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// view runs the function in a read transaction.
+func (s *boltStore) view(fn func(tx *bbolt.Tx) error) error {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+	return s.db.View(fn)
+}
+
+// update runs the function in a write transaction and stores the counters if it is committed.
+func (s *boltStore) update(fn func(tx *bbolt.Tx, c *boltCounts) error) error {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.db == nil {
+		return bbolt.ErrDatabaseNotOpen
+	}
+	s.wmx.Lock()
+	defer s.wmx.Unlock()
+
+	c := boltCounts{len: atomic.LoadInt64(&s.len), size: atomic.LoadInt64(&s.size)}
+	if err := s.db.Update(func(tx *bbolt.Tx) error { return fn(tx, &c) }); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&s.len, c.len)
+	atomic.StoreInt64(&s.size, c.size)
+	return nil
+}
@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func openBolt(t *testing.T, path string, cfg BoltConfig) Bolt {
+	s, err := BoltStore(path, cfg)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, s.Close())
+	})
+	return s
+}
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	s := openBolt(t, filepath.Join(t.TempDir(), "test.db"), BoltConfig{})
+
+	key := "a"
+	err := s.Set(ctx, key, []byte{1, 2, 3})
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	// repeated delete must be safe
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	b, err = s.Get(ctx, key)
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.Nil(t, s.Set(ctx, "b", nil))
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{})
+
+	err = s.Clear(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Len(), 0)
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestBoltStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	s := openBolt(t, filepath.Join(t.TempDir(), "test.db"), BoltConfig{})
+
+	err := s.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, s.Len(), 2)
+
+	n, err := s.DeleteExpired(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, n, 1)
+	assert.Equal(t, s.Len(), 1)
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestBoltStore_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	var failed int32
+	s := openBolt(t, filepath.Join(t.TempDir(), "test.db"), BoltConfig{
+		CleanupInterval: 5 * time.Millisecond,
+		OnError:         func(error) { atomic.AddInt32(&failed, 1) },
+	})
+
+	assert.Nil(t, s.SetWithTTL(ctx, "a", []byte{1}, time.Millisecond))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+
+	assert.Eventually(t, func() bool { return s.Len() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, atomic.LoadInt32(&failed), int32(0))
+}
+
+func TestBoltStore_Limits(t *testing.T) {
+	ctx := context.Background()
+	s := openBolt(t, filepath.Join(t.TempDir(), "test.db"), BoltConfig{MaxEntries: 2, MaxBytes: 10})
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+	// rewriting the entry makes it the last written
+	assert.Nil(t, s.Set(ctx, "a", []byte{3}))
+	assert.Nil(t, s.Set(ctx, "c", []byte{4}))
+
+	_, err := s.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, s.Len(), 2)
+	assert.Equal(t, s.Size(), 4)
+
+	// the entry does not fit until the others are evicted
+	assert.Nil(t, s.Set(ctx, "d", make([]byte, 8)))
+	assert.Equal(t, s.Len(), 1)
+	assert.Equal(t, s.Size(), 9)
+
+	err = s.Set(ctx, "e", make([]byte, 10))
+	assert.True(t, errors.Is(err, ErrEntryTooLarge))
+}
+
+func TestBoltStore_Batch(t *testing.T) {
+	ctx := context.Background()
+	s := openBolt(t, filepath.Join(t.TempDir(), "test.db"), BoltConfig{})
+	users := Namespace(s, "users")
+
+	errs := SetMany(ctx, users, []Entry{{Key: "a", Data: []byte{1}}, {Key: "b", Data: []byte{2}, TTL: time.Minute}})
+	assert.Equal(t, errs, []error{nil, nil})
+	assert.Nil(t, s.Set(ctx, "a", []byte{3}))
+
+	data, errs := GetMany(ctx, users, []string{"a", "b", "c"})
+	assert.Equal(t, data, [][]byte{{1}, {2}, nil})
+	assert.Equal(t, errs, []error{nil, nil, ErrNotFound})
+
+	errs = DeleteMany(ctx, users, []string{"a", "c"})
+	assert.Equal(t, errs, []error{nil, nil})
+	assert.Equal(t, s.Len(), 2)
+
+	assert.Nil(t, users.Clear(ctx))
+	assert.Equal(t, s.Len(), 1)
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{3})
+}
+
+func TestBoltStore_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	s, err := BoltStore(path, BoltConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set(ctx, "a", []byte{1, 2}))
+	assert.Nil(t, s.SetWithTTL(ctx, "b", []byte{3}, time.Minute))
+	assert.Nil(t, s.Close())
+	// repeated close must be safe
+	assert.Nil(t, s.Close())
+
+	s = openBolt(t, path, BoltConfig{MaxEntries: 2})
+	assert.Equal(t, s.Len(), 2)
+	assert.Equal(t, s.Size(), 5)
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2})
+
+	// the order of the writes is kept
+	assert.Nil(t, s.Set(ctx, "c", []byte{4}))
+	_, err = s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestBoltStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s := openBolt(t, path, BoltConfig{})
+
+	entries := make([]Entry, 1000)
+	for i := range entries {
+		entries[i] = Entry{Key: strconv.Itoa(i), Data: make([]byte, 1024)}
+	}
+	assert.Equal(t, SetMany(ctx, s, entries), make([]error, len(entries)))
+	assert.Nil(t, s.Set(ctx, "kept", []byte{1}))
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+	assert.Equal(t, DeleteMany(ctx, s, keys), make([]error, len(keys)))
+
+	before, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Compact(ctx))
+	after, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Less(t, after.Size(), before.Size())
+
+	b, err := s.Get(ctx, "kept")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	assert.Equal(t, s.Len(), 1)
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+}
+
+func TestBoltStore_CompactReopenFails(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s := openBolt(t, path, BoltConfig{})
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+
+	// the compacted database cannot be opened
+	errOpen := errors.New("open failed")
+	boltOpen = func(string, os.FileMode, *bbolt.Options) (*bbolt.DB, error) {
+		boltOpen = bbolt.Open
+		return nil, errOpen
+	}
+	t.Cleanup(func() {
+		boltOpen = bbolt.Open
+	})
+	err := s.Compact(ctx)
+	assert.True(t, errors.Is(err, errOpen))
+
+	// the original database is opened again
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+	for _, name := range []string{path + ".orig", path + ".compact"} {
+		_, err = os.Stat(name)
+		assert.True(t, errors.Is(err, os.ErrNotExist), name)
+	}
+}
+
+func TestBoltStore_CompactInterrupted(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := BoltStore(path, BoltConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.Close())
+
+	// the compaction was interrupted after the original database was moved aside
+	assert.Nil(t, os.Rename(path, path+".orig"))
+	s = openBolt(t, path, BoltConfig{})
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+}
+
+func TestBoltStore_CompactStaleFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// the file of an interrupted compaction
+	stale, err := BoltStore(path+".compact", BoltConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, stale.Set(ctx, "stale", []byte{1}))
+	assert.Nil(t, stale.Close())
+
+	s := openBolt(t, path, BoltConfig{})
+	assert.Nil(t, s.Set(ctx, "a", []byte{2}))
+	assert.Nil(t, s.Compact(ctx))
+
+	_, err = s.Get(ctx, "stale")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+	assert.Equal(t, s.Len(), 1)
+}
@@ -0,0 +1,363 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEntryTooLarge indicates that the entry does not fit into the store even if it is empty.
+var ErrEntryTooLarge = errors.New("entry is too large")
+
+// BoundedConfig configures a bounded in-memory store.
+type BoundedConfig struct {
+	// MaxEntries limits the number of entries. Zero means no limit.
+	MaxEntries int
+	// MaxBytes limits the total size of keys and data. Zero means no limit.
+	MaxBytes int
+	// Policy chooses the entries to evict. LRU is used if nil.
+	Policy EvictionPolicy
+	// OnEvict is called for every entry evicted to free space for new entries.
+	OnEvict func(key string, data []byte)
+}
+
+// Bounded is an in-memory store with limited capacity.
+type Bounded interface {
+	TTLStore
+	// Len returns the number of entries.
+	Len() int
+	// Size returns the total size of keys and data.
+	Size() int
+	// Evictions returns the number of entries evicted since the store was created.
+	Evictions() int
+}
+
+// boundedEntry is an entry of a bounded store, the namespace tells which subscribers get its events.
+type boundedEntry struct {
+	mapEntry
+	ns string
+}
+
+// boundedEvent is the event of an entry of the namespace.
+type boundedEvent struct {
+	Event
+	ns string
+}
+
+// boundedState is shared by the namespaces of a bounded store, the limits apply to all of them.
+type boundedState struct {
+	mx        sync.Mutex
+	m         map[string]boundedEntry // keys are prefixed with the namespaces
+	size      int
+	evictions int
+	cfg       BoundedConfig
+	listeners map[string][]func(Event) // subscribers per namespace
+}
+
+type boundedStore struct {
+	*boundedState
+	ns string
+}
+
+// BoundedStore creates an in-memory store that evicts entries when it exceeds the configured limits.
+func BoundedStore(cfg BoundedConfig) Bounded {
+	if cfg.Policy == nil {
+		cfg.Policy = LRU()
+	}
+	return &boundedStore{boundedState: &boundedState{
+		m:         make(map[string]boundedEntry),
+		cfg:       cfg,
+		listeners: make(map[string][]func(Event)),
+	}}
+}
+
+func (s *boundedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, _, err := s.GetWithTTL(ctx, key)
+	return data, err
+}
+
+func (s *boundedStore) GetWithTTL(_ context.Context, key string) ([]byte, time.Duration, error) {
+	key = s.key(key)
+	s.mx.Lock()
+	e, ok := s.m[key]
+	if !ok {
+		s.mx.Unlock()
+		return nil, 0, ErrNotFound
+	}
+	ttl, ok := remaining(e.expireAt)
+	if !ok {
+		s.remove(key, e)
+		s.mx.Unlock()
+		s.notify([]boundedEvent{newBoundedEvent(Expired, key, e)})
+		return nil, 0, ErrNotFound
+	}
+	s.cfg.Policy.Access(key)
+	s.mx.Unlock()
+	return e.data, ttl, nil
+}
+
+func (s *boundedStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
+}
+
+func (s *boundedStore) SetWithTTL(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	key = s.key(key)
+	if s.cfg.MaxBytes > 0 && entrySize(key, data) > s.cfg.MaxBytes {
+		return ErrEntryTooLarge
+	}
+
+	s.mx.Lock()
+	events := s.set(key, data, ttl, nil)
+	s.mx.Unlock()
+
+	s.notify(events)
+	return nil
+}
+
+func (s *boundedStore) GetMany(_ context.Context, keys []string) ([][]byte, []error) {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	var events []boundedEvent
+
+	s.mx.Lock()
+	for i, key := range keys {
+		key = s.key(key)
+		e, ok := s.m[key]
+		switch {
+		case !ok:
+			errs[i] = ErrNotFound
+		case expired(e.expireAt):
+			s.remove(key, e)
+			errs[i] = ErrNotFound
+			events = append(events, newBoundedEvent(Expired, key, e))
+		default:
+			s.cfg.Policy.Access(key)
+			data[i] = e.data
+		}
+	}
+	s.mx.Unlock()
+
+	s.notify(events)
+	return data, errs
+}
+
+func (s *boundedStore) SetMany(_ context.Context, entries []Entry) []error {
+	errs := make([]error, len(entries))
+	var events []boundedEvent
+
+	s.mx.Lock()
+	for i, e := range entries {
+		key := s.key(e.Key)
+		if s.cfg.MaxBytes > 0 && entrySize(key, e.Data) > s.cfg.MaxBytes {
+			errs[i] = ErrEntryTooLarge
+			continue
+		}
+		events = s.set(key, e.Data, e.TTL, events)
+	}
+	s.mx.Unlock()
+
+	s.notify(events)
+	return errs
+}
+
+func (s *boundedStore) DeleteMany(_ context.Context, keys []string) []error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, key := range keys {
+		key = s.key(key)
+		if e, ok := s.m[key]; ok {
+			s.remove(key, e)
+		}
+	}
+	return make([]error, len(keys))
+}
+
+func (s *boundedStore) Delete(_ context.Context, key string) error {
+	key = s.key(key)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if e, ok := s.m[key]; ok {
+		s.remove(key, e)
+	}
+	return nil
+}
+
+// Clear removes the entries of the namespace and its nested namespaces, or all entries from the root store.
+func (s *boundedStore) Clear(ctx context.Context) error {
+	if s.ns != "" {
+		return s.clearPrefix(ctx, s.ns+namespaceSeparator)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.m = make(map[string]boundedEntry)
+	s.size = 0
+	s.cfg.Policy.Reset()
+	return nil
+}
+
+func (s *boundedStore) Len() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.m)
+}
+
+func (s *boundedStore) Size() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.size
+}
+
+func (s *boundedStore) Evictions() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.evictions
+}
+
+// set stores the entry and evicts other entries if the store exceeds the limits.
+// The events of the evicted and expired entries are appended to the given events.
+func (s *boundedStore) set(key string, data []byte, ttl time.Duration, events []boundedEvent) []boundedEvent {
+	n := entrySize(key, data)
+	entry := boundedEntry{mapEntry: mapEntry{data: data, expireAt: expireAt(ttl)}, ns: s.ns}
+
+	if e, ok := s.m[key]; ok {
+		s.m[key] = entry
+		s.size += n - entrySize(key, e.data)
+		s.cfg.Policy.Access(key)
+		return s.evict(key, 0, 0, events)
+	}
+
+	// a new key joins the policy after eviction, so it cannot be chosen as a victim
+	events = s.evict(key, 1, n, events)
+	s.m[key] = entry
+	s.size += n
+	s.cfg.Policy.Add(key)
+	return events
+}
+
+// evict removes entries until the store fits the limits with room for the extra entries and bytes.
+// The key being set is never evicted, the policies of the package skip it, and it is added back
+// to the other policies.
+func (s *boundedStore) evict(keep string, extraEntries, extraBytes int, events []boundedEvent) []boundedEvent {
+	except, canSkip := s.cfg.Policy.(exceptEvicter)
+	skipped := false
+	for s.overflow(extraEntries, extraBytes) {
+		var key string
+		var ok bool
+		if canSkip {
+			key, ok = except.evictExcept(keep)
+		} else {
+			key, ok = s.cfg.Policy.Evict()
+		}
+		if !ok {
+			break
+		}
+		if key == keep {
+			skipped = true
+			continue
+		}
+
+		e := s.m[key]
+		delete(s.m, key)
+		s.size -= entrySize(key, e.data)
+		if expired(e.expireAt) {
+			events = append(events, newBoundedEvent(Expired, key, e))
+			continue
+		}
+
+		s.evictions++
+		events = append(events, newBoundedEvent(Evicted, key, e))
+	}
+
+	if skipped {
+		s.cfg.Policy.Add(keep)
+	}
+	return events
+}
+
+// Subscribe registers the function called for the evicted and expired entries of the namespace.
+// The entries of the nested namespaces are not reported.
+func (s *boundedStore) Subscribe(fn func(Event)) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.listeners[s.ns] = append(s.listeners[s.ns], fn)
+	return nil
+}
+
+// newBoundedEvent returns the event of the entry, its key is relative to the namespace of the entry.
+func newBoundedEvent(kind EventKind, key string, e boundedEntry) boundedEvent {
+	return boundedEvent{Event: Event{Kind: kind, Key: key, Data: e.data}, ns: e.ns}
+}
+
+// notify calls OnEvict with the store keys and the subscribers of the namespaces outside the lock,
+// so they may use the store.
+func (s *boundedStore) notify(events []boundedEvent) {
+	if len(events) == 0 {
+		return
+	}
+	s.mx.Lock()
+	listeners := make([][]func(Event), len(events))
+	for i, e := range events {
+		listeners[i] = s.listeners[e.ns]
+	}
+	s.mx.Unlock()
+
+	for i, e := range events {
+		if e.Kind == Evicted && s.cfg.OnEvict != nil {
+			s.cfg.OnEvict(e.Key, e.Data)
+		}
+		if len(listeners[i]) == 0 {
+			continue
+		}
+		if e.ns != "" {
+			e.Key = e.Key[len(e.ns)+len(namespaceSeparator):]
+		}
+		for _, fn := range listeners[i] {
+			fn(e.Event)
+		}
+	}
+}
+
+func (s *boundedStore) overflow(extraEntries, extraBytes int) bool {
+	if s.cfg.MaxEntries > 0 && len(s.m)+extraEntries > s.cfg.MaxEntries {
+		return true
+	}
+	return s.cfg.MaxBytes > 0 && s.size+extraBytes > s.cfg.MaxBytes
+}
+
+func (s *boundedStore) remove(key string, e boundedEntry) {
+	delete(s.m, key)
+	s.size -= entrySize(key, e.data)
+	s.cfg.Policy.Remove(key)
+}
+
+func entrySize(key string, data []byte) int {
+	return len(key) + len(data)
+}
+
+func (s *boundedStore) Namespace(name string) Store {
+	if s.ns != "" {
+		name = s.ns + namespaceSeparator + name
+	}
+	return &boundedStore{boundedState: s.boundedState, ns: name}
+}
+
+// key returns the key of the entry in the map, which is unique across namespaces.
+func (s *boundedStore) key(key string) string {
+	if s.ns == "" {
+		return key
+	}
+	return s.ns + namespaceSeparator + key
+}
+
+func (s *boundedStore) clearPrefix(_ context.Context, prefix string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for key, e := range s.m {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(key, e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBoundedStore(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxEntries: 10})
+
+	key := "a"
+	err := s.Set(ctx, key, nil)
+	assert.Nil(t, err)
+
+	key = "b"
+	err = s.Set(ctx, key, []byte{1, 2, 3})
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	// repeated delete must be safe
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	b, err = s.Get(ctx, key)
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = s.Clear(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Len(), 0)
+	assert.Equal(t, s.Size(), 0)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestBoundedStore_MaxEntries(t *testing.T) {
+	ctx := context.Background()
+	var evicted []string
+	s := BoundedStore(BoundedConfig{
+		MaxEntries: 2,
+		OnEvict: func(key string, data []byte) {
+			evicted = append(evicted, key)
+		},
+	})
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+	_, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set(ctx, "c", []byte{3}))
+
+	_, err = s.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, evicted, []string{"b"})
+	assert.Equal(t, s.Len(), 2)
+	assert.Equal(t, s.Evictions(), 1)
+
+	// update does not evict
+	assert.Nil(t, s.Set(ctx, "c", []byte{4}))
+	assert.Equal(t, s.Evictions(), 1)
+}
+
+func TestBoundedStore_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxBytes: 10, Policy: LFU()})
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1, 2, 3, 4}))
+	assert.Nil(t, s.Set(ctx, "b", []byte{1, 2, 3}))
+	assert.Equal(t, s.Size(), 9)
+	_, err := s.Get(ctx, "b")
+	assert.Nil(t, err)
+
+	// the entry does not fit until "a" is evicted
+	assert.Nil(t, s.Set(ctx, "c", []byte{1, 2}))
+	assert.Equal(t, s.Size(), 7)
+	assert.Equal(t, s.Evictions(), 1)
+
+	_, err = s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// the growing entry evicts others, but not itself
+	assert.Nil(t, s.Set(ctx, "c", []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	assert.Equal(t, s.Len(), 1)
+	assert.Equal(t, s.Size(), 9)
+
+	err = s.Set(ctx, "d", make([]byte, 10))
+	assert.True(t, errors.Is(err, ErrEntryTooLarge))
+}
+
+func TestBoundedStore_EvictKeepsFrequency(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxBytes: 10, Policy: LFU()})
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	for _, key := range []string{"b", "c"} {
+		assert.Nil(t, s.Set(ctx, key, []byte{1}))
+		for i := 0; i < 2; i++ {
+			_, err := s.Get(ctx, key)
+			assert.Nil(t, err)
+		}
+	}
+
+	// the growing entry is the least frequently used one, it is skipped with its frequency
+	assert.Nil(t, s.Set(ctx, "a", []byte{1, 2, 3, 4, 5, 6}))
+	_, err := s.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+
+	// "a" is used as often as "c" and more recently
+	assert.Nil(t, s.Set(ctx, "d", []byte{1}))
+	_, err = s.Get(ctx, "c")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+}
+
+func TestBoundedStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxEntries: 10, Policy: TwoQueue()})
+
+	err := s.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, s.Len(), 1)
+	assert.Equal(t, s.Evictions(), 0)
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestBoundedStore_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	policies := map[string]EvictionPolicy{"lru": LRU(), "lfu": LFU(), "2q": TwoQueue()}
+
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			s := BoundedStore(BoundedConfig{MaxEntries: 100, MaxBytes: 1000, Policy: policy})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for k := 0; k < 1000; k++ {
+						key := string(rune('a' + (i*k)%50))
+						assert.Nil(t, s.Set(ctx, key, make([]byte, k%20)))
+						if _, err := s.Get(ctx, key); err != nil {
+							assert.True(t, errors.Is(err, ErrNotFound))
+						}
+						assert.Nil(t, s.Delete(ctx, string(rune('a'+k%50))))
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			assert.LessOrEqual(t, s.Len(), 100)
+			assert.LessOrEqual(t, s.Size(), 1000)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import "strconv"
+
+// Dialect generates the SQL statements specific to a database.
+type Dialect interface {
+	// Name is the name of the database.
+	Name() string
+	// Quote quotes the identifier.
+	Quote(name string) string
+	// Placeholder returns the query parameter at the position, which starts with 1.
+	Placeholder(n int) string
+	// CreateTable returns the statements creating the table of the entries and its indexes unless they exist.
+	// The table has the "key", "data", "expire_at" and "namespace" columns.
+	CreateTable(table string) []string
+	// Upsert returns the statement inserting the entry or replacing the entry of the same key.
+	// Its parameters are the key, the data, the expiration time and the namespace.
+	Upsert(table string) string
+}
+
+// SQLite returns the dialect of SQLite.
+func SQLite() Dialect {
+	return sqliteDialect{}
+}
+
+// Postgres returns the dialect of PostgreSQL 9.5 and newer.
+func Postgres() Dialect {
+	return postgresDialect{}
+}
+
+// MySQL returns the dialect of MySQL 5.7 and newer, and MariaDB.
+func MySQL() Dialect {
+	return mysqlDialect{}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) Quote(name string) string { return `"` + name + `"` }
+
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (d sqliteDialect) CreateTable(table string) []string {
+	return []string{
+		sqliteTable(table),
+		`CREATE INDEX IF NOT EXISTS "` + table + `_namespace" ON "` + table + `" ("namespace");`,
+		`CREATE INDEX IF NOT EXISTS "` + table + `_expire_at" ON "` + table + `" ("expire_at") WHERE "expire_at" > 0;`,
+	}
+}
+
+func (sqliteDialect) Upsert(table string) string {
+	return `INSERT OR REPLACE INTO "` + table + `" ("key", "data", "expire_at", "namespace") VALUES (?, ?, ?, ?)`
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Quote(name string) string { return `"` + name + `"` }
+
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// CreateTable uses the "C" collation, so the namespaces are ordered by bytes as the range of Clear expects.
+func (postgresDialect) CreateTable(table string) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS "` + table + `" ("key" TEXT COLLATE "C" NOT NULL PRIMARY KEY, "data" BYTEA,
+		"expire_at" BIGINT NOT NULL DEFAULT 0, "namespace" TEXT COLLATE "C" NOT NULL DEFAULT '');`,
+		`CREATE INDEX IF NOT EXISTS "` + table + `_namespace" ON "` + table + `" ("namespace");`,
+		`CREATE INDEX IF NOT EXISTS "` + table + `_expire_at" ON "` + table + `" ("expire_at") WHERE "expire_at" > 0;`,
+	}
+}
+
+func (postgresDialect) Upsert(table string) string {
+	return `INSERT INTO "` + table + `" ("key", "data", "expire_at", "namespace") VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("key") DO UPDATE SET "data" = EXCLUDED."data", "expire_at" = EXCLUDED."expire_at", "namespace" = EXCLUDED."namespace"`
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) Quote(name string) string { return "`" + name + "`" }
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+// CreateTable uses the binary columns, so the keys are case-sensitive. MySQL has no CREATE INDEX IF NOT EXISTS,
+// the indexes are created along with the table.
+func (mysqlDialect) CreateTable(table string) []string {
+	return []string{
+		"CREATE TABLE IF NOT EXISTS `" + table + "` (`key` VARBINARY(512) NOT NULL PRIMARY KEY, `data` LONGBLOB,\n" +
+			"\t\t`expire_at` BIGINT NOT NULL DEFAULT 0, `namespace` VARBINARY(512) NOT NULL DEFAULT '',\n" +
+			"\t\tINDEX `" + table + "_namespace` (`namespace`), INDEX `" + table + "_expire_at` (`expire_at`));",
+	}
+}
+
+func (mysqlDialect) Upsert(table string) string {
+	return "INSERT INTO `" + table + "` (`key`, `data`, `expire_at`, `namespace`) VALUES (?, ?, ?, ?)\n" +
+		"\t\tON DUPLICATE KEY UPDATE `data` = VALUES(`data`), `expire_at` = VALUES(`expire_at`), `namespace` = VALUES(`namespace`)"
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestDialect_Golden checks the statements generated by the dialects against testdata/*.sql,
+// run the test with -update after changing the statements.
+func TestDialect_Golden(t *testing.T) {
+	for _, d := range []Dialect{SQLite(), Postgres(), MySQL()} {
+		t.Run(d.Name(), func(t *testing.T) {
+			q := newSQLQueries(d, "gcache_cache")
+			statements := append(d.CreateTable("gcache_cache"),
+				q.get, q.set, q.del, q.clear, q.clearNS, q.expired, q.getMany(3), q.deleteMany(3))
+			got := strings.Join(statements, "\n\n") + "\n"
+
+			filename := filepath.Join("testdata", d.Name()+".sql")
+			if *update {
+				assert.Nil(t, os.WriteFile(filename, []byte(got), 0o644))
+			}
+			want, err := os.ReadFile(filename)
+			assert.Nil(t, err)
+			assert.Equal(t, got, string(want))
+		})
+	}
+}
+
+func TestDialect_Placeholder(t *testing.T) {
+	assert.Equal(t, placeholders(SQLite(), 3), "?,?,?")
+	assert.Equal(t, placeholders(Postgres(), 3), "$1,$2,$3")
+	assert.Equal(t, placeholders(MySQL(), 1), "?")
+}
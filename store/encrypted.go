@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"sync"
+	"time"
+)
+
+// Cipher is the authenticated encryption algorithm of an encrypted store.
+type Cipher byte
+
+const (
+	// AESGCM is AES in Galois/Counter Mode, the key is 16, 24 or 32 bytes long.
+	AESGCM Cipher = iota + 1
+	// XChaCha20Poly1305 is XChaCha20-Poly1305 with 24-byte nonces, the key is 32 bytes long.
+	XChaCha20Poly1305
+)
+
+// encryptedVersion is the version of the payload format:
+// version | cipher | key ID length | key ID | nonce | ciphertext and tag.
+// The header before the nonce is authenticated along with the data.
+const encryptedVersion = 1
+
+var (
+	// ErrUnknownKey indicates that the key provider does not have the key of the ID.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrDecryption indicates that the data is malformed, was encrypted by another key,
+	// or was copied from another store key when the store keys are bound.
+	ErrDecryption = errors.New("cannot decrypt data")
+)
+
+// KeyProvider provides the keys of an encrypted store. A key ID must always identify the same key,
+// the keys are rotated by changing the current key ID while the previous keys are still provided for decryption.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key that encrypt the new data. The ID is at most 255 bytes long.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key of the ID stored with the data.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// StaticKeys creates a key provider of the fixed keys, the current key encrypts the new data.
+func StaticKeys(current string, keys map[string][]byte) KeyProvider {
+	return &staticKeys{current: current, keys: keys}
+}
+
+func (k *staticKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := k.Key(ctx, k.current)
+	return k.current, key, err
+}
+
+func (k *staticKeys) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// EncryptionConfig configures an encrypted store.
+type EncryptionConfig struct {
+	Keys KeyProvider
+	// Cipher is AESGCM by default.
+	Cipher Cipher
+	// BindKeys authenticates the store key and namespace along with the data,
+	// so the data copied under another key or namespace fails to decrypt.
+	BindKeys bool
+}
+
+// encryptedStore is shared by the namespaces of an encrypted store.
+type encryptedStore struct {
+	s     Store
+	cfg   EncryptionConfig
+	ns    string
+	aeads *sync.Map // key ID -> cipher.AEAD
+}
+
+// EncryptedStore creates a store that encrypts the data before it is written to the underlying store
+// and decrypts the data read from it. The keys are not encrypted.
+func EncryptedStore(s Store, cfg EncryptionConfig) Store {
+	if cfg.Cipher == 0 {
+		cfg.Cipher = AESGCM
+	}
+	return &encryptedStore{s: s, cfg: cfg, aeads: &sync.Map{}}
+}
+
+func (e *encryptedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := e.s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return e.decrypt(ctx, key, b)
+}
+
+// GetWithTTL returns ErrTTLNotSupported unless the underlying store implements ExpiringStore.
+func (e *encryptedStore) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	b, ttl, err := GetWithTTL(ctx, e.s, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err = e.decrypt(ctx, key, b)
+	return b, ttl, err
+}
+
+func (e *encryptedStore) getManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, []error) {
+	data, ttls, errs := getManyWithTTL(ctx, e.s, keys)
+	for i, b := range data {
+		if errs[i] == nil {
+			data[i], errs[i] = e.decrypt(ctx, keys[i], b)
+		}
+	}
+	return data, ttls, errs
+}
+
+func (e *encryptedStore) Set(ctx context.Context, key string, data []byte) error {
+	return e.SetWithTTL(ctx, key, data, 0)
+}
+
+func (e *encryptedStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	b, err := e.encrypt(ctx, key, data)
+	if err != nil {
+		return err
+	}
+	return SetWithTTL(ctx, e.s, key, b, ttl)
+}
+
+func (e *encryptedStore) Delete(ctx context.Context, key string) error {
+	return e.s.Delete(ctx, key)
+}
+
+func (e *encryptedStore) Clear(ctx context.Context) error {
+	return e.s.Clear(ctx)
+}
+
+// Subscribe registers the function called for the events of the underlying store with the decrypted data.
+// The events of the data that cannot be decrypted are not reported.
+func (e *encryptedStore) Subscribe(fn func(Event)) error {
+	return Subscribe(e.s, func(ev Event) {
+		data, err := e.decrypt(context.Background(), ev.Key, ev.Data)
+		if err != nil {
+			return
+		}
+		ev.Data = data
+		fn(ev)
+	})
+}
+
+func (e *encryptedStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	data, errs := GetMany(ctx, e.s, keys)
+	for i, b := range data {
+		if errs[i] == nil {
+			data[i], errs[i] = e.decrypt(ctx, keys[i], b)
+		}
+	}
+	return data, errs
+}
+
+func (e *encryptedStore) SetMany(ctx context.Context, entries []Entry) []error {
+	errs := make([]error, len(entries))
+	encrypted := make([]Entry, 0, len(entries))
+	indexes := make([]int, 0, len(entries))
+	for i, entry := range entries {
+		b, err := e.encrypt(ctx, entry.Key, entry.Data)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		encrypted = append(encrypted, Entry{Key: entry.Key, Data: b, TTL: entry.TTL})
+		indexes = append(indexes, i)
+	}
+
+	for j, err := range SetMany(ctx, e.s, encrypted) {
+		errs[indexes[j]] = err
+	}
+	return errs
+}
+
+func (e *encryptedStore) DeleteMany(ctx context.Context, keys []string) []error {
+	return DeleteMany(ctx, e.s, keys)
+}
+
+// Namespace encrypts the data of the namespace of the underlying store.
+// The namespace is bound to the data along with the key.
+func (e *encryptedStore) Namespace(name string) Store {
+	return &encryptedStore{s: Namespace(e.s, name), cfg: e.cfg, ns: e.ns + name + namespaceSeparator, aeads: e.aeads}
+}
+
+func (e *encryptedStore) encrypt(ctx context.Context, key string, data []byte) ([]byte, error) {
+	id, k, err := e.cfg.Keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, errors.New("encryption key ID is longer than 255 bytes")
+	}
+	aead, err := e.aead(id, k)
+	if err != nil {
+		return nil, err
+	}
+
+	header := 3 + len(id)
+	b := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(data)+aead.Overhead())
+	b[0] = encryptedVersion
+	b[1] = byte(e.cfg.Cipher)
+	b[2] = byte(len(id))
+	copy(b[3:], id)
+
+	nonce := b[header:]
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(b, nonce, data, e.associatedData(b[:header], key)), nil
+}
+
+func (e *encryptedStore) decrypt(ctx context.Context, key string, b []byte) ([]byte, error) {
+	if len(b) < 3 || b[0] != encryptedVersion || Cipher(b[1]) != e.cfg.Cipher || len(b) < 3+int(b[2]) {
+		return nil, ErrDecryption
+	}
+	header := 3 + int(b[2])
+	id := string(b[3:header])
+
+	aead, err := e.aead(id, nil)
+	if errors.Is(err, ErrUnknownKey) {
+		var k []byte
+		if k, err = e.cfg.Keys.Key(ctx, id); err == nil {
+			aead, err = e.aead(id, k)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < header+aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	nonce := b[header : header+aead.NonceSize()]
+	data, err := aead.Open(nil, nonce, b[header+aead.NonceSize():], e.associatedData(b[:header], key))
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return data, nil
+}
+
+// aead returns the cipher of the key ID, it is created from the key on the first use.
+// A nil key only looks up the cipher.
+func (e *encryptedStore) aead(id string, key []byte) (cipher.AEAD, error) {
+	if v, ok := e.aeads.Load(id); ok {
+		return v.(cipher.AEAD), nil
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	var aead cipher.AEAD
+	var err error
+	switch e.cfg.Cipher {
+	case AESGCM:
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case XChaCha20Poly1305:
+		aead, err = chacha20poly1305.NewX(key)
+	default:
+		err = errors.New("unknown cipher")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.aeads.Store(id, aead)
+	return aead, nil
+}
+
+// associatedData authenticates the header, and the namespace and the key if they are bound.
+// The namespace and the key are length-prefixed, so no other pair of them has the same data.
+func (e *encryptedStore) associatedData(header []byte, key string) []byte {
+	if !e.cfg.BindKeys {
+		return header
+	}
+	ad := make([]byte, 0, len(header)+2*binary.MaxVarintLen64+len(e.ns)+len(key))
+	ad = append(ad, header...)
+	ad = appendLengthPrefixed(ad, e.ns)
+	return appendLengthPrefixed(ad, key)
+}
+
+func appendLengthPrefixed(b []byte, s string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+	return append(b, s...)
+}
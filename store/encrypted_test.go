@@ -0,0 +1,143 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestEncryptedStore(t *testing.T) {
+	for _, c := range []Cipher{AESGCM, XChaCha20Poly1305} {
+		ctx := context.Background()
+		m := MapStore(0)
+		s := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1}), Cipher: c})
+
+		err := s.Set(ctx, "key", []byte("some value"))
+		assert.Nil(t, err)
+
+		b, err := m.Get(ctx, "key")
+		assert.Nil(t, err)
+		assert.False(t, bytes.Contains(b, []byte("some value")))
+		assert.Equal(t, b[:4], []byte{encryptedVersion, byte(c), 1, '1'})
+
+		b, err = s.Get(ctx, "key")
+		assert.Nil(t, err)
+		assert.Equal(t, b, []byte("some value"))
+
+		// without key binding the data copied under another key is decrypted
+		b, _ = m.Get(ctx, "key")
+		_ = m.Set(ctx, "copy", b)
+		b, err = s.Get(ctx, "copy")
+		assert.Nil(t, err)
+		assert.Equal(t, b, []byte("some value"))
+
+		_, err = s.Get(ctx, "missing")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+}
+
+func TestEncryptedStore_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	m := MapStore(0)
+	s := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1})})
+	assert.Nil(t, s.Set(ctx, "old", []byte("old value")))
+
+	rotated := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("2", map[string][]byte{"1": testKey1, "2": testKey2})})
+	assert.Nil(t, rotated.Set(ctx, "new", []byte("new value")))
+
+	b, err := rotated.Get(ctx, "old")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("old value"))
+	b, err = rotated.Get(ctx, "new")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("new value"))
+
+	// the old store does not know the new key
+	_, err = s.Get(ctx, "new")
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+
+	// the key of the same ID does not match
+	other := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey2})})
+	_, err = other.Get(ctx, "old")
+	assert.True(t, errors.Is(err, ErrDecryption))
+}
+
+func TestEncryptedStore_BindKeys(t *testing.T) {
+	ctx := context.Background()
+	m := MapStore(0)
+	s := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1}), BindKeys: true})
+
+	assert.Nil(t, s.Set(ctx, "key", []byte("some value")))
+	b, err := s.Get(ctx, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte("some value"))
+
+	b, _ = m.Get(ctx, "key")
+	_ = m.Set(ctx, "copy", b)
+	_, err = s.Get(ctx, "copy")
+	assert.True(t, errors.Is(err, ErrDecryption))
+
+	// the namespace is bound too
+	users := Namespace(s, "users")
+	assert.Nil(t, users.Set(ctx, "key", []byte("user")))
+	b, _ = Namespace(m, "users").Get(ctx, "key")
+	_ = Namespace(m, "names").Set(ctx, "key", b)
+	_, err = Namespace(s, "names").Get(ctx, "key")
+	assert.True(t, errors.Is(err, ErrDecryption))
+
+	// the namespace and the key are not confused at the separator
+	assert.Nil(t, Namespace(s, "a").Set(ctx, "b:c", []byte("a value")))
+	b, _ = Namespace(m, "a").Get(ctx, "b:c")
+	_ = Namespace(Namespace(m, "a"), "b").Set(ctx, "c", b)
+	_, err = Namespace(Namespace(s, "a"), "b").Get(ctx, "c")
+	assert.True(t, errors.Is(err, ErrDecryption))
+
+	// malformed data
+	for _, b = range [][]byte{nil, {encryptedVersion}, {encryptedVersion, byte(AESGCM), 10}, {encryptedVersion, byte(AESGCM), 1, '1', 0}} {
+		_ = m.Set(ctx, "malformed", b)
+		_, err = s.Get(ctx, "malformed")
+		assert.True(t, errors.Is(err, ErrDecryption))
+	}
+}
+
+func TestEncryptedStore_Header(t *testing.T) {
+	ctx := context.Background()
+	m := MapStore(0)
+	// the IDs of the same key
+	s := EncryptedStore(m, EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1, "2": testKey1})})
+
+	assert.Nil(t, s.Set(ctx, "key", []byte("some value")))
+	b, err := m.Get(ctx, "key")
+	assert.Nil(t, err)
+	b[3] = '2'
+	assert.Nil(t, m.Set(ctx, "key", b))
+	_, err = s.Get(ctx, "key")
+	assert.True(t, errors.Is(err, ErrDecryption))
+}
+
+func TestEncryptedStore_InvalidKey(t *testing.T) {
+	ctx := context.Background()
+	s := EncryptedStore(MapStore(0), EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": {1, 2, 3}})})
+	assert.NotNil(t, s.Set(ctx, "key", []byte("some value")))
+
+	s = EncryptedStore(MapStore(0), EncryptionConfig{Keys: StaticKeys("2", map[string][]byte{"1": testKey1})})
+	err := s.Set(ctx, "key", []byte("some value"))
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+	errs := SetMany(ctx, s, []Entry{{Key: "key", Data: []byte("some value")}})
+	assert.True(t, errors.Is(errs[0], ErrUnknownKey))
+}
+
+func TestBatch_EncryptedStore(t *testing.T) {
+	testBatchStore(t, EncryptedStore(MapStore(0), EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1}), BindKeys: true}))
+}
+
+func TestNamespace_EncryptedStore(t *testing.T) {
+	testNamespaceStore(t, EncryptedStore(MapStore(0), EncryptionConfig{Keys: StaticKeys("1", map[string][]byte{"1": testKey1}), BindKeys: true}))
+}
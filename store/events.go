@@ -0,0 +1,51 @@
+package store
+
+import "errors"
+
+// EventKind is the reason why a store removed an entry by itself.
+type EventKind int
+
+const (
+	// Evicted entries were removed to free space for new entries.
+	Evicted EventKind = iota + 1
+	// Expired entries were removed after their time-to-live had passed.
+	Expired
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// Event reports an entry removed by the store rather than by Delete or Clear.
+type Event struct {
+	Kind EventKind
+	Key  string
+	Data []byte
+}
+
+// ErrEventsNotSupported indicates that the store does not report the entries it evicts or expires.
+var ErrEventsNotSupported = errors.New("store does not support events")
+
+// EventStore is the interface implemented by stores that report the entries they evict or expire.
+// The expired entries are reported when the store notices them, e.g. on reading, not at the expiration time.
+type EventStore interface {
+	Store
+	// Subscribe registers the function called for every event. The function is called
+	// outside the locks of the store, so it may use the store.
+	Subscribe(fn func(Event)) error
+}
+
+// Subscribe registers the function called for every event of the store,
+// or returns ErrEventsNotSupported if the store does not implement EventStore.
+func Subscribe(s Store, fn func(Event)) error {
+	if es, ok := s.(EventStore); ok {
+		return es.Subscribe(fn)
+	}
+	return ErrEventsNotSupported
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder collects the events of a store.
+type eventRecorder struct {
+	mx     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) get() []Event {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.events
+}
+
+func TestBoundedStore_Events(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxEntries: 2})
+	var r eventRecorder
+	assert.Nil(t, Subscribe(s, r.record))
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+	assert.Nil(t, s.Set(ctx, "c", []byte{3}))
+	assert.Equal(t, r.get(), []Event{{Kind: Evicted, Key: "a", Data: []byte{1}}})
+
+	assert.Nil(t, s.Delete(ctx, "b"))
+	assert.Nil(t, s.Clear(ctx))
+	assert.Len(t, r.get(), 1) // deletes are not events
+
+	assert.Nil(t, SetWithTTL(ctx, s, "d", []byte{4}, time.Millisecond))
+	assert.Nil(t, SetWithTTL(ctx, s, "e", []byte{5}, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := s.Get(ctx, "d")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, errs := GetMany(ctx, s, []string{"e"})
+	assert.True(t, errors.Is(errs[0], ErrNotFound))
+	assert.Equal(t, r.get()[1:], []Event{
+		{Kind: Expired, Key: "d", Data: []byte{4}},
+		{Kind: Expired, Key: "e", Data: []byte{5}},
+	})
+
+	// an expired victim is reported as expired
+	assert.Nil(t, s.Set(ctx, "f", []byte{6}))
+	assert.Nil(t, SetWithTTL(ctx, s, "g", []byte{7}, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = s.Get(ctx, "f")
+	assert.Nil(t, s.Set(ctx, "h", []byte{8}))
+	assert.Equal(t, r.get()[3:], []Event{{Kind: Expired, Key: "g", Data: []byte{7}}})
+	assert.Equal(t, s.Evictions(), 1)
+}
+
+func TestMapStore_Events(t *testing.T) {
+	ctx := context.Background()
+	s := MapStore(0)
+	ns := Namespace(s, "ns")
+	var r, nsr eventRecorder
+	assert.Nil(t, Subscribe(s, r.record))
+	assert.Nil(t, Subscribe(ns, nsr.record))
+
+	assert.Nil(t, SetWithTTL(ctx, s, "a", []byte{1}, time.Millisecond))
+	assert.Nil(t, SetWithTTL(ctx, ns, "a", []byte{2}, time.Millisecond))
+	assert.Nil(t, SetWithTTL(ctx, ns, "b", []byte{3}, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, errs := GetMany(ctx, ns, []string{"a", "b"})
+	assert.True(t, errors.Is(errs[0], ErrNotFound))
+
+	assert.Equal(t, r.get(), []Event{{Kind: Expired, Key: "a", Data: []byte{1}}})
+	assert.Equal(t, nsr.get(), []Event{
+		{Kind: Expired, Key: "a", Data: []byte{2}},
+		{Kind: Expired, Key: "b", Data: []byte{3}},
+	})
+}
+
+func TestBoundedStore_NamespaceEvents(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxEntries: 1})
+	ns := Namespace(s, "ns")
+	nested := Namespace(ns, "nested")
+	var r, nsr, nestedr eventRecorder
+	assert.Nil(t, Subscribe(s, r.record))
+	assert.Nil(t, Subscribe(ns, nsr.record))
+	assert.Nil(t, Subscribe(nested, nestedr.record))
+
+	// every subscriber gets the events of its own namespace with the keys of the namespace
+	assert.Nil(t, nested.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, ns.Set(ctx, "a", []byte{2}))
+	assert.Nil(t, s.Set(ctx, "a", []byte{3}))
+	assert.Nil(t, ns.Set(ctx, "b", []byte{4}))
+	assert.Equal(t, nestedr.get(), []Event{{Kind: Evicted, Key: "a", Data: []byte{1}}})
+	assert.Equal(t, nsr.get(), []Event{{Kind: Evicted, Key: "a", Data: []byte{2}}})
+	assert.Equal(t, r.get(), []Event{{Kind: Evicted, Key: "a", Data: []byte{3}}})
+
+	b, err := ns.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{4})
+	_, err = s.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Nil(t, s.Clear(ctx))
+	_, err = ns.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestEncryptedStore_Events(t *testing.T) {
+	ctx := context.Background()
+	s := EncryptedStore(BoundedStore(BoundedConfig{MaxEntries: 1}), EncryptionConfig{
+		Keys: StaticKeys("1", map[string][]byte{"1": testKey1}),
+	})
+	ns := Namespace(s, "ns")
+	var r eventRecorder
+	assert.Nil(t, Subscribe(ns, r.record))
+
+	assert.Nil(t, ns.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, ns.Set(ctx, "b", []byte{2}))
+	assert.Equal(t, r.get(), []Event{{Kind: Evicted, Key: "a", Data: []byte{1}}})
+	assert.True(t, errors.Is(Subscribe(Tiered(s), r.record), ErrEventsNotSupported))
+}
+
+func TestNamespace_Events(t *testing.T) {
+	ctx := context.Background()
+	s := BoundedStore(BoundedConfig{MaxEntries: 1})
+	var r eventRecorder
+	assert.Nil(t, Subscribe(Namespace(s, "ns"), r.record))
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, Namespace(s, "ns").Set(ctx, "b", []byte{2}))
+	assert.Nil(t, s.Set(ctx, "c", []byte{3}))
+	assert.Equal(t, r.get(), []Event{{Kind: Evicted, Key: "b", Data: []byte{2}}})
+
+	assert.True(t, errors.Is(Subscribe(RedisStore(nil), r.record), ErrEventsNotSupported))
+	assert.True(t, errors.Is(Subscribe(Namespace(RedisStore(nil), "ns"), r.record), ErrEventsNotSupported))
+}
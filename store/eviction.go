@@ -0,0 +1,266 @@
+package store
+
+import "container/list"
+
+// EvictionPolicy decides which entry is removed first when a bounded store is full.
+// Implementations are not safe for concurrent use and must not be shared between stores.
+type EvictionPolicy interface {
+	// Add registers a new key.
+	Add(key string)
+	// Access marks that the key was read or updated.
+	Access(key string)
+	// Remove unregisters the key.
+	Remove(key string)
+	// Evict unregisters and returns the key that has to be evicted next.
+	Evict() (string, bool)
+	// Reset unregisters all keys.
+	Reset()
+}
+
+// exceptEvicter is implemented by the policies of the package, which skip the key being set when they evict,
+// so the key keeps its position and frequency.
+type exceptEvicter interface {
+	evictExcept(keep string) (string, bool)
+}
+
+type lruPolicy struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// LRU creates a policy that evicts the least recently used entry.
+func LRU() EvictionPolicy {
+	return &lruPolicy{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) Add(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.MoveToFront(e)
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+func (p *lruPolicy) Access(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) Remove(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.Remove(e)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruPolicy) Evict() (string, bool) {
+	return p.evict("", false)
+}
+
+func (p *lruPolicy) evictExcept(keep string) (string, bool) {
+	return p.evict(keep, true)
+}
+
+// evict removes the least recently used key, the kept key is skipped if skip is set.
+func (p *lruPolicy) evict(keep string, skip bool) (string, bool) {
+	for e := p.ll.Back(); e != nil; e = e.Prev() {
+		if key := e.Value.(string); !skip || key != keep {
+			p.ll.Remove(e)
+			delete(p.items, key)
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (p *lruPolicy) Reset() {
+	p.ll.Init()
+	p.items = make(map[string]*list.Element)
+}
+
+type lfuBucket struct {
+	freq  int
+	items *list.List
+}
+
+type lfuItem struct {
+	key    string
+	bucket *list.Element
+	elem   *list.Element
+}
+
+type lfuPolicy struct {
+	buckets *list.List // ordered by ascending frequency
+	items   map[string]*lfuItem
+}
+
+// LFU creates a policy that evicts the least frequently used entry.
+// Entries with equal frequency are evicted in the least recently used order.
+func LFU() EvictionPolicy {
+	return &lfuPolicy{buckets: list.New(), items: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) Add(key string) {
+	if _, ok := p.items[key]; ok {
+		p.Access(key)
+		return
+	}
+
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+
+	item := &lfuItem{key: key, bucket: front}
+	item.elem = front.Value.(*lfuBucket).items.PushFront(item)
+	p.items[key] = item
+}
+
+func (p *lfuPolicy) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	cur := item.bucket
+	freq := cur.Value.(*lfuBucket).freq + 1
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != freq {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: freq, items: list.New()}, cur)
+	}
+
+	p.unlink(item)
+	item.bucket = next
+	item.elem = next.Value.(*lfuBucket).items.PushFront(item)
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.unlink(item)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfuPolicy) Evict() (string, bool) {
+	return p.evict("", false)
+}
+
+func (p *lfuPolicy) evictExcept(keep string) (string, bool) {
+	return p.evict(keep, true)
+}
+
+// evict removes the least frequently used key, the kept key is skipped if skip is set.
+func (p *lfuPolicy) evict(keep string, skip bool) (string, bool) {
+	for b := p.buckets.Front(); b != nil; b = b.Next() {
+		for e := b.Value.(*lfuBucket).items.Back(); e != nil; e = e.Prev() {
+			if item := e.Value.(*lfuItem); !skip || item.key != keep {
+				p.unlink(item)
+				delete(p.items, item.key)
+				return item.key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *lfuPolicy) Reset() {
+	p.buckets.Init()
+	p.items = make(map[string]*lfuItem)
+}
+
+// unlink removes the item from its bucket and drops the bucket if it becomes empty.
+func (p *lfuPolicy) unlink(item *lfuItem) {
+	b := item.bucket.Value.(*lfuBucket)
+	b.items.Remove(item.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+}
+
+const (
+	// twoQueueInRatio is the share of entries kept in the FIFO queue of recently added entries.
+	twoQueueInRatio = 0.25
+	// twoQueueGhostRatio is the number of remembered evicted keys relative to the number of entries.
+	twoQueueGhostRatio = 0.5
+)
+
+type twoQueuePolicy struct {
+	in    *lruPolicy // entries seen once, in FIFO order
+	main  *lruPolicy // entries seen again, in LRU order
+	ghost *lruPolicy // keys recently evicted from the in queue
+}
+
+// TwoQueue creates a 2Q policy. New entries go to a FIFO queue and are promoted to the LRU queue
+// only if they are added again soon after eviction, so one-time scans do not flush frequently used entries.
+func TwoQueue() EvictionPolicy {
+	return &twoQueuePolicy{
+		in:    LRU().(*lruPolicy),
+		main:  LRU().(*lruPolicy),
+		ghost: LRU().(*lruPolicy),
+	}
+}
+
+func (p *twoQueuePolicy) Add(key string) {
+	if _, ok := p.main.items[key]; ok {
+		p.main.Access(key)
+		return
+	}
+	if _, ok := p.in.items[key]; ok {
+		return
+	}
+	if _, ok := p.ghost.items[key]; ok {
+		p.ghost.Remove(key)
+		p.main.Add(key)
+		return
+	}
+	p.in.Add(key)
+}
+
+func (p *twoQueuePolicy) Access(key string) {
+	// accesses of the entries in the in queue are correlated and do not promote them
+	p.main.Access(key)
+}
+
+func (p *twoQueuePolicy) Remove(key string) {
+	p.in.Remove(key)
+	p.main.Remove(key)
+}
+
+func (p *twoQueuePolicy) Evict() (string, bool) {
+	return p.evict("", false)
+}
+
+func (p *twoQueuePolicy) evictExcept(keep string) (string, bool) {
+	return p.evict(keep, true)
+}
+
+// evict removes a key of the in queue if it is over its share, or of the main queue otherwise.
+// The kept key is skipped if skip is set, and the queues are sized without it.
+func (p *twoQueuePolicy) evict(keep string, skip bool) (string, bool) {
+	resident := p.in.ll.Len() + p.main.ll.Len()
+	in, main := p.in.ll.Len(), p.main.ll.Len()
+	if skip {
+		if _, ok := p.in.items[keep]; ok {
+			in--
+		} else if _, ok = p.main.items[keep]; ok {
+			main--
+		}
+	}
+
+	if in > 0 && (float64(in) > twoQueueInRatio*float64(in+main) || main == 0) {
+		key, _ := p.in.evict(keep, skip)
+		p.ghost.Add(key)
+		for p.ghost.ll.Len() > 1 && float64(p.ghost.ll.Len()) > twoQueueGhostRatio*float64(resident) {
+			p.ghost.Evict()
+		}
+		return key, true
+	}
+	return p.main.evict(keep, skip)
+}
+
+func (p *twoQueuePolicy) Reset() {
+	p.in.Reset()
+	p.main.Reset()
+	p.ghost.Reset()
+}
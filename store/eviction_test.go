@@ -0,0 +1,113 @@
+package store
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func evictAll(p EvictionPolicy) []string {
+	var keys []string
+	for {
+		key, ok := p.Evict()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	p := LRU()
+	p.Add("a")
+	p.Add("b")
+	p.Add("c")
+	p.Access("a")
+	p.Access("unknown")
+	assert.Equal(t, evictAll(p), []string{"b", "c", "a"})
+
+	p.Add("a")
+	p.Add("b")
+	p.Remove("a")
+	assert.Equal(t, evictAll(p), []string{"b"})
+
+	p.Add("a")
+	p.Reset()
+	assert.Empty(t, evictAll(p))
+}
+
+func TestLFU(t *testing.T) {
+	p := LFU()
+	p.Add("a")
+	p.Add("b")
+	p.Add("c")
+	p.Access("a")
+	p.Access("a")
+	p.Access("c")
+	p.Access("unknown")
+	assert.Equal(t, evictAll(p), []string{"b", "c", "a"})
+
+	// equal frequency is evicted in LRU order
+	p.Add("a")
+	p.Add("b")
+	p.Add("c")
+	p.Access("b")
+	p.Access("a")
+	assert.Equal(t, evictAll(p), []string{"c", "b", "a"})
+
+	p.Add("a")
+	p.Add("b")
+	p.Access("a")
+	p.Remove("a")
+	assert.Equal(t, evictAll(p), []string{"b"})
+
+	p.Add("a")
+	p.Reset()
+	assert.Empty(t, evictAll(p))
+}
+
+func TestTwoQueue(t *testing.T) {
+	p := TwoQueue()
+	p.Add("a")
+	p.Add("b")
+
+	// the entry added again after eviction is promoted to the LRU queue
+	key, ok := p.Evict()
+	assert.True(t, ok)
+	assert.Equal(t, key, "a")
+	p.Add("a")
+
+	// one-time scan does not flush the promoted entry
+	for _, k := range []string{"c", "d", "e", "f"} {
+		p.Add(k)
+		key, ok = p.Evict()
+		assert.True(t, ok)
+		assert.NotEqual(t, key, "a")
+	}
+
+	p.Remove("a")
+	p.Reset()
+	assert.Empty(t, evictAll(p))
+}
+
+func TestEvictExcept(t *testing.T) {
+	for name, p := range map[string]EvictionPolicy{"lru": LRU(), "lfu": LFU(), "2q": TwoQueue()} {
+		p.Add("a")
+		p.Add("b")
+		p.Add("c")
+		p.Access("b")
+		p.Access("c")
+
+		// the kept key is skipped and keeps its place
+		except := p.(exceptEvicter)
+		key, ok := except.evictExcept("a")
+		assert.True(t, ok, name)
+		assert.Equal(t, key, "b", name)
+		key, ok = p.Evict()
+		assert.True(t, ok, name)
+		assert.Equal(t, key, "a", name)
+
+		_, ok = except.evictExcept("c")
+		assert.False(t, ok, name)
+		assert.Equal(t, evictAll(p), []string{"c"}, name)
+	}
+}
@@ -0,0 +1,542 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fileMagic starts the files of the entries.
+var fileMagic = [2]byte{0xc1, 'f'}
+
+// fileHeaderSize is the size of the magic, the expiration time, the data size and the data checksum.
+const fileHeaderSize = 2 + 8 + 8 + 4
+
+// fileNamespaces is the directory of the namespaces in the directory of a store.
+const fileNamespaces = "ns"
+
+// fileTemp is the infix of the files being written, they are renamed to the files of the entries when complete.
+const fileTemp = ".tmp-"
+
+// errCorruptFile indicates a file of an entry that is not complete or whose data does not match the checksum.
+var errCorruptFile = errors.New("corrupt cache file")
+
+// FileConfig configures a file system store.
+type FileConfig struct {
+	// MaxBytes limits the total size of the files. Zero means no limit. When the limit is exceeded, the files
+	// read least recently are deleted until the total size is below 90% of the limit.
+	MaxBytes int
+	// MaxAge expires the entries by the modification time of their files, i.e. the time they were written.
+	// Zero means no limit.
+	MaxAge time.Duration
+	// CleanupInterval is the period of deleting the expired and corrupt files, which are never read but take space.
+	// The files are not deleted in the background if it is zero.
+	CleanupInterval time.Duration
+	// OnError is called for the errors of the background cleanup and eviction.
+	OnError func(err error)
+	// Sync flushes the files to the disk before they replace the previous files of the keys,
+	// so the entries survive a power loss.
+	Sync bool
+}
+
+// Files is a store keeping every entry in a file, e.g. to cache large artifacts.
+type Files interface {
+	TTLStore
+	// Len returns the number of files, including the expired files not deleted yet.
+	Len() int
+	// Size returns the total size of the files.
+	Size() int
+	// Cleanup deletes the expired and corrupt files and the files exceeding the size limit.
+	Cleanup(ctx context.Context) error
+	// Close stops the background cleanup.
+	Close() error
+}
+
+// fileState is shared by the namespaces of a file store.
+type fileState struct {
+	root string
+	cfg  FileConfig
+	// locks serialize the writes of the files sharing the first byte of the name.
+	locks    [256]sync.Mutex
+	len      int64
+	size     int64
+	evicting sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+type fileStore struct {
+	st  *fileState
+	dir string
+}
+
+// FileStore creates a store keeping every entry in a file of the directory.
+func FileStore(dir string) (Files, error) {
+	return FileStoreWithConfig(dir, FileConfig{})
+}
+
+// FileStoreWithConfig creates a store keeping every entry in a file of the directory. The files are sharded
+// into subdirectories by the first bytes of the SHA-256 keys made by MsgpackHasher, the other keys are hashed.
+// The files are written to temporary files renamed when complete, so a file is never read partially written.
+// The directory is scanned to count the files, and the files left incomplete or expired are deleted.
+func FileStoreWithConfig(dir string, cfg FileConfig) (Files, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	st := &fileState{root: dir, cfg: cfg, done: make(chan struct{})}
+	if err := st.scan(); err != nil {
+		return nil, err
+	}
+	if cfg.CleanupInterval > 0 {
+		st.wg.Add(1)
+		go st.cleanup()
+	}
+	return &fileStore{st: st, dir: dir}, nil
+}
+
+func (s *fileStore) Get(_ context.Context, key string) ([]byte, error) {
+	path := s.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, expireAt, err := readFile(f, fi.Size(), true)
+	if errors.Is(err, errCorruptFile) {
+		_ = s.st.remove(path, fi)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if expired(expireAt) || s.st.tooOld(fi) {
+		return nil, ErrNotFound
+	}
+
+	s.st.touch(path, fi)
+	return data, nil
+}
+
+func (s *fileStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
+}
+
+func (s *fileStore) SetWithTTL(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	size := int64(fileHeaderSize + len(data))
+	if s.st.cfg.MaxBytes > 0 && size > int64(s.st.cfg.MaxBytes) {
+		return ErrEntryTooLarge
+	}
+
+	path := s.path(key)
+	tmp, err := s.st.writeTemp(path, data, expireAt(ttl))
+	if err != nil {
+		return err
+	}
+
+	mx := s.st.lock(path)
+	mx.Lock()
+	prev, statErr := os.Stat(path)
+	if err = os.Rename(tmp, path); err == nil {
+		if statErr == nil {
+			atomic.AddInt64(&s.st.size, size-prev.Size())
+		} else {
+			atomic.AddInt64(&s.st.len, 1)
+			atomic.AddInt64(&s.st.size, size)
+		}
+	}
+	mx.Unlock()
+
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if s.st.cfg.MaxBytes > 0 && atomic.LoadInt64(&s.st.size) > int64(s.st.cfg.MaxBytes) {
+		s.st.fail(s.st.evict(context.Background()))
+	}
+	return nil
+}
+
+func (s *fileStore) Delete(_ context.Context, key string) error {
+	path := s.path(key)
+	mx := s.st.lock(path)
+	mx.Lock()
+	defer mx.Unlock()
+
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.st.removeLocked(path, fi)
+}
+
+// Clear deletes the files of the namespace and its nested namespaces, or all files of the store.
+// The directories are kept, so they do not disappear under the concurrent writes.
+func (s *fileStore) Clear(ctx context.Context) error {
+	return s.st.walk(ctx, s.dir, func(path string, fi fs.FileInfo) error {
+		return s.st.remove(path, fi)
+	})
+}
+
+func (s *fileStore) Namespace(name string) Store {
+	return &fileStore{st: s.st, dir: filepath.Join(s.dir, fileNamespaces, escapeDirName(name))}
+}
+
+func (s *fileStore) Len() int {
+	return int(atomic.LoadInt64(&s.st.len))
+}
+
+func (s *fileStore) Size() int {
+	return int(atomic.LoadInt64(&s.st.size))
+}
+
+func (s *fileStore) Cleanup(ctx context.Context) error {
+	if err := s.st.deleteExpired(ctx); err != nil {
+		return err
+	}
+	return s.st.evict(ctx)
+}
+
+func (s *fileStore) Close() error {
+	s.st.once.Do(func() {
+		close(s.st.done)
+		s.st.wg.Wait()
+	})
+	return nil
+}
+
+// path returns the path of the file of the key, e.g. "ab/cd/abcd...".
+func (s *fileStore) path(key string) string {
+	name := fileName(key)
+	return filepath.Join(s.dir, name[:2], name[2:4], name)
+}
+
+// fileName returns the SHA-256 key as it is, or the SHA-256 hash of any other key.
+func fileName(key string) string {
+	if isFileName(key) {
+		return key
+	}
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// isFileName reports whether the name is a lowercase hex SHA-256 hash.
+func isFileName(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeDirName makes the namespace a name of a directory.
+func escapeDirName(name string) string {
+	e := url.PathEscape(name)
+	if strings.Trim(e, ".") == "" {
+		// "", "." and ".." are not names of directories
+		e = "%" + strings.ReplaceAll(e, ".", "%2E")
+	}
+	return e
+}
+
+// writeTemp writes the entry to a temporary file next to the file of the entry.
+func (st *fileState) writeTemp(path string, data []byte, expireAt int64) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+fileTemp+"*")
+	if err != nil {
+		return "", err
+	}
+
+	var header [fileHeaderSize]byte
+	copy(header[:], fileMagic[:])
+	binary.BigEndian.PutUint64(header[2:], uint64(expireAt))
+	binary.BigEndian.PutUint64(header[10:], uint64(len(data)))
+	binary.BigEndian.PutUint32(header[18:], crc32.ChecksumIEEE(data))
+
+	_, err = f.Write(header[:])
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil && st.cfg.Sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readFile reads the header and, if the data is read, the data of the file of the given size.
+func readFile(r io.Reader, size int64, readData bool) ([]byte, int64, error) {
+	var header [fileHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorruptFile
+		}
+		return nil, 0, err
+	}
+	n := binary.BigEndian.Uint64(header[10:])
+	if header[0] != fileMagic[0] || header[1] != fileMagic[1] || uint64(size) != fileHeaderSize+n {
+		return nil, 0, errCorruptFile
+	}
+	expireAt := int64(binary.BigEndian.Uint64(header[2:]))
+	if !readData {
+		return nil, expireAt, nil
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorruptFile
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[18:]) {
+		return nil, 0, errCorruptFile
+	}
+	return data, expireAt, nil
+}
+
+// readHeader returns the expiration time of the file.
+func readHeader(path string, fi fs.FileInfo) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	_, expireAt, err := readFile(f, fi.Size(), false)
+	return expireAt, err
+}
+
+func (st *fileState) lock(path string) *sync.Mutex {
+	b, _ := strconv.ParseUint(filepath.Base(path)[:2], 16, 8)
+	return &st.locks[b]
+}
+
+// tooOld reports whether the file was written earlier than the max age.
+func (st *fileState) tooOld(fi fs.FileInfo) bool {
+	return st.cfg.MaxAge > 0 && time.Since(fi.ModTime()) > st.cfg.MaxAge
+}
+
+// touch updates the access time of the file unless it was replaced since its info was taken,
+// the access time orders the eviction, it is not updated by the file systems mounted with noatime.
+func (st *fileState) touch(path string, fi fs.FileInfo) {
+	mx := st.lock(path)
+	mx.Lock()
+	defer mx.Unlock()
+
+	if cur, err := os.Stat(path); err == nil && os.SameFile(cur, fi) {
+		_ = os.Chtimes(path, time.Now(), fi.ModTime())
+	}
+}
+
+// remove deletes the file unless it was replaced since its info was taken.
+func (st *fileState) remove(path string, fi fs.FileInfo) error {
+	mx := st.lock(path)
+	mx.Lock()
+	defer mx.Unlock()
+
+	cur, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(cur, fi) {
+		return nil
+	}
+	return st.removeLocked(path, cur)
+}
+
+func (st *fileState) removeLocked(path string, fi fs.FileInfo) error {
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	atomic.AddInt64(&st.len, -1)
+	atomic.AddInt64(&st.size, -fi.Size())
+	return nil
+}
+
+// walk calls the function for the files of the entries in the directory and its subdirectories.
+func (st *fileState) walk(ctx context.Context, dir string, fn func(path string, fi fs.FileInfo) error) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !isFileName(d.Name()) {
+			return nil
+		}
+		fi, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(path, fi)
+	})
+	return err
+}
+
+// scan counts the files of the entries, and deletes the temporary, corrupt and expired files.
+func (st *fileState) scan() error {
+	var n, size int64
+	err := filepath.WalkDir(st.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if i := strings.Index(name, fileTemp); i >= 0 && isFileName(name[:i]) {
+			// the file was being written when the process stopped
+			return os.Remove(path)
+		}
+		if !isFileName(name) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		expireAt, err := readHeader(path, fi)
+		if errors.Is(err, errCorruptFile) || err == nil && (expired(expireAt) || st.tooOld(fi)) {
+			return os.Remove(path)
+		}
+		if err != nil {
+			return err
+		}
+		n++
+		size += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&st.len, n)
+	atomic.StoreInt64(&st.size, size)
+	return nil
+}
+
+// deleteExpired deletes the expired and corrupt files.
+func (st *fileState) deleteExpired(ctx context.Context) error {
+	return st.walk(ctx, st.root, func(path string, fi fs.FileInfo) error {
+		if st.tooOld(fi) {
+			return st.remove(path, fi)
+		}
+		expireAt, err := readHeader(path, fi)
+		switch {
+		case errors.Is(err, errCorruptFile), err == nil && expired(expireAt):
+			return st.remove(path, fi)
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		}
+		return err
+	})
+}
+
+// evict deletes the files read least recently until the total size is below 90% of the limit.
+// The eviction is skipped while another one is running.
+func (st *fileState) evict(ctx context.Context) error {
+	limit := int64(st.cfg.MaxBytes)
+	if limit <= 0 || atomic.LoadInt64(&st.size) <= limit || !st.evicting.TryLock() {
+		return nil
+	}
+	defer st.evicting.Unlock()
+
+	type file struct {
+		path  string
+		fi    fs.FileInfo
+		atime time.Time
+	}
+	var files []file
+	err := st.walk(ctx, st.root, func(path string, fi fs.FileInfo) error {
+		files = append(files, file{path: path, fi: fi, atime: accessTime(fi)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].atime.Before(files[j].atime) })
+	for _, f := range files {
+		if atomic.LoadInt64(&st.size) <= limit*9/10 {
+			break
+		}
+		if err = st.remove(f.path, f.fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *fileState) cleanup() {
+	defer st.wg.Done()
+	ticker := time.NewTicker(st.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-st.done:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if err := st.deleteExpired(ctx); err != nil {
+				st.fail(err)
+				continue
+			}
+			st.fail(st.evict(ctx))
+		}
+	}
+}
+
+func (st *fileState) fail(err error) {
+	if err != nil && st.cfg.OnError != nil {
+		st.cfg.OnError(err)
+	}
+}
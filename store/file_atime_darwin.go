@@ -0,0 +1,15 @@
+package store
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the time the file was read last.
+func accessTime(fi fs.FileInfo) time.Time {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atimespec.Sec), int64(st.Atimespec.Nsec))
+	}
+	return fi.ModTime()
+}
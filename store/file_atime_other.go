@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package store
+
+import (
+	"io/fs"
+	"time"
+)
+
+// accessTime returns the time the file was written last, the access time is not available on the platform.
+func accessTime(fi fs.FileInfo) time.Time {
+	return fi.ModTime()
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sha256Key is the key of "some key" made by MsgpackHasher.
+const sha256Key = "0dc44df765b1ef70e8b5069777b6cb177fdeef0cc977327b9e19e4a3dad24818"
+
+func openFiles(t *testing.T, dir string, cfg FileConfig) Files {
+	s, err := FileStoreWithConfig(dir, cfg)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, s.Close())
+	})
+	return s
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := FileStore(dir)
+	assert.Nil(t, err)
+
+	err = s.Set(ctx, sha256Key, []byte{1, 2, 3})
+	assert.Nil(t, err)
+
+	// the SHA-256 keys are sharded by their first bytes
+	_, err = os.Stat(filepath.Join(dir, "0d", "c4", sha256Key))
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, sha256Key)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+	assert.Equal(t, s.Len(), 1)
+	assert.Equal(t, s.Size(), fileHeaderSize+3)
+
+	err = s.Delete(ctx, sha256Key)
+	assert.Nil(t, err)
+
+	// repeated delete must be safe
+	err = s.Delete(ctx, sha256Key)
+	assert.Nil(t, err)
+
+	b, err = s.Get(ctx, sha256Key)
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// any other key is hashed
+	assert.Nil(t, s.Set(ctx, "../a", nil))
+	b, err = s.Get(ctx, "../a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{})
+
+	err = s.Clear(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Len(), 0)
+	assert.Equal(t, s.Size(), 0)
+
+	b, err = s.Get(ctx, "../a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Nil(t, s.Close())
+}
+
+func TestFileStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	s := openFiles(t, t.TempDir(), FileConfig{})
+
+	err := s.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, s.Len(), 2)
+
+	assert.Nil(t, s.Cleanup(ctx))
+	assert.Equal(t, s.Len(), 1)
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestFileStore_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openFiles(t, dir, FileConfig{MaxAge: time.Hour, CleanupInterval: 5 * time.Millisecond})
+
+	assert.Nil(t, s.Set(ctx, sha256Key, []byte{1}))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+
+	// the file was written two hours ago
+	old := time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "0d", "c4", sha256Key), old, old))
+
+	_, err := s.Get(ctx, sha256Key)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Eventually(t, func() bool { return s.Len() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestFileStore_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	size := fileHeaderSize + 10
+	s := openFiles(t, dir, FileConfig{MaxBytes: 4 * size})
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, s.Set(ctx, key, make([]byte, 10)))
+	}
+	assert.Equal(t, s.Size(), 4*size)
+
+	// "a" is read most recently, the others are evicted down to 90% of the limit
+	past := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		name := fileName(key)
+		at := past.Add(time.Duration(i) * time.Minute)
+		assert.Nil(t, os.Chtimes(filepath.Join(dir, name[:2], name[2:4], name), at, at))
+	}
+	_, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Set(ctx, "e", make([]byte, 10)))
+	assert.Equal(t, s.Len(), 3)
+	for key, found := range map[string]bool{"a": true, "b": false, "c": false, "d": true, "e": true} {
+		_, err = s.Get(ctx, key)
+		assert.Equal(t, err == nil, found, key)
+	}
+
+	err = s.Set(ctx, "f", make([]byte, 4*size))
+	assert.True(t, errors.Is(err, ErrEntryTooLarge))
+}
+
+func TestFileStore_TouchReplaced(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openFiles(t, dir, FileConfig{})
+	path := filepath.Join(dir, "0d", "c4", sha256Key)
+
+	assert.Nil(t, s.Set(ctx, sha256Key, []byte{1}))
+	old := time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, os.Chtimes(path, old, old))
+	fi, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	// the file replaced after it was read keeps its modification time
+	assert.Nil(t, s.Set(ctx, sha256Key, []byte{2}))
+	cur, err := os.Stat(path)
+	assert.Nil(t, err)
+	s.(*fileStore).st.touch(path, fi)
+	after, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, after.ModTime(), cur.ModTime())
+
+	s.(*fileStore).st.touch(path, after)
+	touched, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, touched.ModTime(), cur.ModTime())
+	assert.True(t, accessTime(touched).After(old))
+}
+
+func TestFileStore_Corrupt(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openFiles(t, dir, FileConfig{})
+
+	path := func(key string) string {
+		name := fileName(key)
+		return filepath.Join(dir, name[:2], name[2:4], name)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, s.Set(ctx, key, []byte{1, 2, 3}))
+	}
+
+	// partial file
+	assert.Nil(t, os.Truncate(path("a"), fileHeaderSize+1))
+	// corrupt data
+	b, err := os.ReadFile(path("b"))
+	assert.Nil(t, err)
+	b[len(b)-1] ^= 0xff
+	assert.Nil(t, os.WriteFile(path("b"), b, 0o644))
+	// not a file of an entry
+	assert.Nil(t, os.WriteFile(path("c"), []byte("foreign"), 0o644))
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err = s.Get(ctx, key)
+		assert.True(t, errors.Is(err, ErrNotFound), key)
+		_, err = os.Stat(path(key))
+		assert.True(t, errors.Is(err, os.ErrNotExist), key)
+	}
+	b, err = s.Get(ctx, "d")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+	assert.Equal(t, s.Len(), 1)
+}
+
+func TestFileStore_Scan(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := FileStore(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set(ctx, "a", []byte{1, 2}))
+	assert.Nil(t, s.SetWithTTL(ctx, "b", []byte{3}, time.Millisecond))
+	assert.Nil(t, Namespace(s, "users").Set(ctx, "c", []byte{4}))
+	assert.Nil(t, s.Close())
+
+	// the temporary file of an interrupted write and a partial file are deleted
+	name := fileName("d")
+	tmp := filepath.Join(dir, name[:2], name[2:4], name+fileTemp+"123")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(tmp), 0o755))
+	assert.Nil(t, os.WriteFile(tmp, []byte{1}, 0o644))
+	name = fileName("e")
+	partial := filepath.Join(dir, name[:2], name[2:4], name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(partial), 0o755))
+	assert.Nil(t, os.WriteFile(partial, fileMagic[:], 0o644))
+	// the other files are kept
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README"), []byte{1}, 0o644))
+	time.Sleep(5 * time.Millisecond)
+
+	s = openFiles(t, dir, FileConfig{})
+	assert.Equal(t, s.Len(), 2)
+	assert.Equal(t, s.Size(), 2*fileHeaderSize+3)
+	for _, path := range []string{tmp, partial} {
+		_, err = os.Stat(path)
+		assert.True(t, errors.Is(err, os.ErrNotExist), path)
+	}
+	_, err = os.Stat(filepath.Join(dir, "README"))
+	assert.Nil(t, err)
+
+	b, err := Namespace(s, "users").Get(ctx, "c")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{4})
+}
+
+func TestFileStore_Namespace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openFiles(t, dir, FileConfig{})
+
+	users := Namespace(s, "users")
+	admins := Namespace(users, "admins")
+	for _, ns := range []Store{s, users, admins, Namespace(s, ".."), Namespace(s, "a/b")} {
+		assert.Nil(t, ns.Set(ctx, "a", []byte{1}))
+	}
+	assert.Equal(t, s.Len(), 5)
+
+	// the namespaces stay in the directory
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(path, dir), path)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, users.Clear(ctx))
+	assert.Equal(t, s.Len(), 3)
+	_, err = admins.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	assert.Nil(t, s.Clear(ctx))
+	assert.Equal(t, s.Len(), 0)
+	_, err = Namespace(s, "..").Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestFileStore_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	size := fileHeaderSize + 10
+	s := openFiles(t, t.TempDir(), FileConfig{MaxBytes: 20 * size})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < 50; k++ {
+				key := string(rune('a' + (i+k)%26))
+				assert.Nil(t, s.Set(ctx, key, make([]byte, 10)))
+				_, _ = s.Get(ctx, key)
+				if k%7 == 0 {
+					assert.Nil(t, s.Delete(ctx, key))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, s.Size(), 20*size)
+	assert.Equal(t, s.Size(), s.Len()*size)
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"sync"
+	"time"
+)
+
+// invalidationChannel is the default Redis channel of the invalidation messages.
+const invalidationChannel = "gcache:invalidation"
+
+// InvalidationConfig configures an invalidated store.
+type InvalidationConfig struct {
+	// Client publishes and receives the invalidation messages.
+	Client redis.UniversalClient
+	// Channel is "gcache:invalidation" by default. The stores sharing a channel invalidate each other.
+	Channel string
+	// InvalidateOnSet also evicts the keys written by Set from the other stores,
+	// so that they do not keep the previous values.
+	InvalidateOnSet bool
+	// OnError is called for the messages that cannot be decoded or applied.
+	OnError func(err error)
+}
+
+// Invalidated is a store whose deletions and clears are applied to the other stores subscribed to the channel.
+type Invalidated interface {
+	Store
+	// Close unsubscribes from the channel.
+	Close() error
+}
+
+// invalidation is a message of the channel.
+type invalidation struct {
+	Origin    string   `json:"o"`
+	Namespace []string `json:"ns,omitempty"`
+	Keys      []string `json:"k,omitempty"`
+	Clear     bool     `json:"c,omitempty"`
+}
+
+// invalidationBus is shared by the namespaces of an invalidated store.
+type invalidationBus struct {
+	cfg    InvalidationConfig
+	origin string
+	local  Store
+	pubsub *redis.PubSub
+	done   chan struct{}
+	once   sync.Once
+}
+
+type invalidatedStore struct {
+	s   Store
+	bus *invalidationBus
+	ns  []string
+}
+
+// InvalidatedStore creates a store that broadcasts the deletions and clears of the local store, e.g. MapStore
+// used as a local cache of many replicas, over Redis pub/sub, and applies the deletions and clears received
+// from the other replicas. The messages are not delivered to the replicas that are disconnected at the time.
+func InvalidatedStore(ctx context.Context, s Store, cfg InvalidationConfig) (Invalidated, error) {
+	if cfg.Client == nil {
+		return nil, errors.New("redis client is not set")
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = invalidationChannel
+	}
+
+	origin := make([]byte, 16)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, err
+	}
+
+	pubsub := cfg.Client.Subscribe(ctx, cfg.Channel)
+	// wait for the confirmation, so that no message published after the return is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	bus := &invalidationBus{
+		cfg:    cfg,
+		origin: hex.EncodeToString(origin),
+		local:  s,
+		pubsub: pubsub,
+		done:   make(chan struct{}),
+	}
+	go bus.receive(pubsub.Channel())
+	return &invalidatedStore{s: s, bus: bus}, nil
+}
+
+func (i *invalidatedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return i.s.Get(ctx, key)
+}
+
+func (i *invalidatedStore) Set(ctx context.Context, key string, data []byte) error {
+	return i.SetWithTTL(ctx, key, data, 0)
+}
+
+func (i *invalidatedStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if err := SetWithTTL(ctx, i.s, key, data, ttl); err != nil {
+		return err
+	}
+	if i.bus.cfg.InvalidateOnSet {
+		return i.publish(ctx, invalidation{Keys: []string{key}})
+	}
+	return nil
+}
+
+func (i *invalidatedStore) Delete(ctx context.Context, key string) error {
+	if err := i.s.Delete(ctx, key); err != nil {
+		return err
+	}
+	return i.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+func (i *invalidatedStore) Clear(ctx context.Context) error {
+	if err := i.s.Clear(ctx); err != nil {
+		return err
+	}
+	return i.publish(ctx, invalidation{Clear: true})
+}
+
+func (i *invalidatedStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	return GetMany(ctx, i.s, keys)
+}
+
+func (i *invalidatedStore) SetMany(ctx context.Context, entries []Entry) []error {
+	errs := SetMany(ctx, i.s, entries)
+	if !i.bus.cfg.InvalidateOnSet {
+		return errs
+	}
+
+	keys := make([]string, 0, len(entries))
+	for j, e := range entries {
+		if errs[j] == nil {
+			keys = append(keys, e.Key)
+		}
+	}
+	return i.publishKeys(ctx, keys, errs)
+}
+
+func (i *invalidatedStore) DeleteMany(ctx context.Context, keys []string) []error {
+	errs := DeleteMany(ctx, i.s, keys)
+	deleted := make([]string, 0, len(keys))
+	for j, key := range keys {
+		if errs[j] == nil {
+			deleted = append(deleted, key)
+		}
+	}
+	return i.publishKeys(ctx, deleted, errs)
+}
+
+// Namespace broadcasts the deletions and clears of the namespace of the local store.
+func (i *invalidatedStore) Namespace(name string) Store {
+	ns := make([]string, len(i.ns), len(i.ns)+1)
+	copy(ns, i.ns)
+	return &invalidatedStore{s: Namespace(i.s, name), bus: i.bus, ns: append(ns, name)}
+}
+
+// Subscribe registers the function called for the events of the local store.
+func (i *invalidatedStore) Subscribe(fn func(Event)) error {
+	return Subscribe(i.s, fn)
+}
+
+func (i *invalidatedStore) Close() error {
+	return i.bus.close()
+}
+
+func (i *invalidatedStore) publish(ctx context.Context, msg invalidation) error {
+	msg.Origin = i.bus.origin
+	msg.Namespace = i.ns
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return i.bus.cfg.Client.Publish(ctx, i.bus.cfg.Channel, b).Err()
+}
+
+// publishKeys publishes the keys and sets the error of publishing them to the keys without errors.
+func (i *invalidatedStore) publishKeys(ctx context.Context, keys []string, errs []error) []error {
+	if len(keys) == 0 {
+		return errs
+	}
+	if err := i.publish(ctx, invalidation{Keys: keys}); err != nil {
+		for j := range errs {
+			if errs[j] == nil {
+				errs[j] = err
+			}
+		}
+	}
+	return errs
+}
+
+func (b *invalidationBus) receive(ch <-chan *redis.Message) {
+	defer close(b.done)
+	for m := range ch {
+		var msg invalidation
+		if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+			b.fail(err)
+			continue
+		}
+		if msg.Origin == b.origin {
+			continue
+		}
+		b.apply(msg)
+	}
+}
+
+// apply deletes the keys or clears the namespace of the local store.
+func (b *invalidationBus) apply(msg invalidation) {
+	ctx := context.Background()
+	s := b.local
+	for _, name := range msg.Namespace {
+		s = Namespace(s, name)
+	}
+
+	if msg.Clear {
+		b.fail(s.Clear(ctx))
+		return
+	}
+	for _, err := range DeleteMany(ctx, s, msg.Keys) {
+		b.fail(err)
+	}
+}
+
+func (b *invalidationBus) fail(err error) {
+	if err != nil && b.cfg.OnError != nil {
+		b.cfg.OnError(err)
+	}
+}
+
+func (b *invalidationBus) close() error {
+	var err error
+	b.once.Do(func() {
+		err = b.pubsub.Close()
+		<-b.done
+	})
+	return err
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// replica is a local store of a replica with its invalidated store.
+type replica struct {
+	local Store
+	Invalidated
+}
+
+func newReplica(t *testing.T, rdb redis.UniversalClient, onSet bool) replica {
+	local := MapStore(0)
+	s, err := InvalidatedStore(context.Background(), local, InvalidationConfig{Client: rdb, InvalidateOnSet: onSet})
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return replica{local: local, Invalidated: s}
+}
+
+// evicted waits until the key is evicted from the local store.
+func evicted(t *testing.T, s Store, key string) {
+	assert.Eventually(t, func() bool {
+		_, err := s.Get(context.Background(), key)
+		return errors.Is(err, ErrNotFound)
+	}, time.Second, time.Millisecond)
+}
+
+func TestInvalidatedStore(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	a, b := newReplica(t, rdb, false), newReplica(t, rdb, false)
+
+	for _, r := range []replica{a, b} {
+		assert.Nil(t, r.Set(ctx, "x", []byte{1}))
+		assert.Nil(t, r.Set(ctx, "y", []byte{2}))
+		assert.Nil(t, r.Set(ctx, "z", []byte{3}))
+	}
+
+	assert.Nil(t, a.Delete(ctx, "x"))
+	evicted(t, b.local, "x")
+
+	assert.Nil(t, DeleteMany(ctx, a.Invalidated, []string{"y"})[0])
+	evicted(t, b.local, "y")
+
+	// a set is not broadcast by default
+	assert.Nil(t, a.Set(ctx, "z", []byte{4}))
+	v, err := b.Get(ctx, "z")
+	assert.Nil(t, err)
+	assert.Equal(t, v, []byte{3})
+
+	assert.Nil(t, b.Clear(ctx))
+	evicted(t, a.local, "z")
+}
+
+func TestInvalidatedStore_Namespace(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	a, b := newReplica(t, rdb, true), newReplica(t, rdb, true)
+
+	users := Namespace(Namespace(b.Invalidated, "app"), "users")
+	assert.Nil(t, users.Set(ctx, "x", []byte{1}))
+	assert.Nil(t, b.Set(ctx, "x", []byte{2}))
+
+	// the set of another replica evicts the key from the namespace only
+	assert.Nil(t, Namespace(Namespace(a.Invalidated, "app"), "users").Set(ctx, "x", []byte{3}))
+	evicted(t, users, "x")
+	v, err := b.Get(ctx, "x")
+	assert.Nil(t, err)
+	assert.Equal(t, v, []byte{2})
+
+	assert.Nil(t, users.Set(ctx, "y", []byte{1}))
+	assert.Nil(t, Namespace(a.Invalidated, "app").Clear(ctx))
+	evicted(t, users, "y")
+	_, err = b.Get(ctx, "x")
+	assert.Nil(t, err)
+
+	// a replica does not invalidate itself
+	assert.Nil(t, a.Set(ctx, "w", []byte{1}))
+	assert.Nil(t, SetMany(ctx, a.Invalidated, []Entry{{Key: "w", Data: []byte{2}}})[0])
+	time.Sleep(10 * time.Millisecond)
+	v, err = a.Get(ctx, "w")
+	assert.Nil(t, err)
+	assert.Equal(t, v, []byte{2})
+}
+
+func TestInvalidatedStore_Errors(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+
+	_, err := InvalidatedStore(ctx, MapStore(0), InvalidationConfig{})
+	assert.NotNil(t, err)
+
+	errs := make(chan error, 1)
+	s, err := InvalidatedStore(ctx, MapStore(0), InvalidationConfig{
+		Client:  rdb,
+		OnError: func(err error) { errs <- err },
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, rdb.Publish(ctx, invalidationChannel, "malformed").Err())
+	select {
+	case err = <-errs:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("malformed message is not reported")
+	}
+
+	assert.Nil(t, s.Close())
+	assert.Nil(t, s.Close())
+
+	m.Close()
+	assert.NotNil(t, s.Delete(ctx, "x"))
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// namespaceSeparator separates the namespace from the key in the stores that prefix keys.
+const namespaceSeparator = ":"
+
+// ErrClearNotSupported indicates that the store cannot clear a namespace without clearing other namespaces.
+var ErrClearNotSupported = errors.New("store does not support clearing a namespace")
+
+// NamespaceStore is the interface implemented by stores that can partition entries into namespaces.
+type NamespaceStore interface {
+	Store
+	// Namespace returns a view of the store whose keys do not collide with the keys of other namespaces,
+	// and whose Clear removes only the entries of the namespace.
+	Namespace(name string) Store
+}
+
+// Namespace returns a view of the store limited to the namespace. The stores that do not implement
+// NamespaceStore get their keys prefixed with the namespace, but Clear of such a view returns ErrClearNotSupported.
+func Namespace(s Store, name string) Store {
+	if ns, ok := s.(NamespaceStore); ok {
+		return ns.Namespace(name)
+	}
+	return newPrefixStore(s, name, func(context.Context, string) error {
+		return ErrClearNotSupported
+	})
+}
+
+// prefixStore prefixes the keys of the underlying store and clears the namespace with the function
+// provided by the underlying store.
+type prefixStore struct {
+	s           Store
+	prefix      string
+	clearPrefix func(ctx context.Context, prefix string) error
+}
+
+func newPrefixStore(s Store, name string, clearPrefix func(context.Context, string) error) *prefixStore {
+	return &prefixStore{s: s, prefix: name + namespaceSeparator, clearPrefix: clearPrefix}
+}
+
+func (p *prefixStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.s.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixStore) Set(ctx context.Context, key string, data []byte) error {
+	return p.s.Set(ctx, p.prefix+key, data)
+}
+
+func (p *prefixStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return SetWithTTL(ctx, p.s, p.prefix+key, data, ttl)
+}
+
+func (p *prefixStore) Delete(ctx context.Context, key string) error {
+	return p.s.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixStore) Clear(ctx context.Context) error {
+	return p.clearPrefix(ctx, p.prefix)
+}
+
+// Subscribe registers the function called for the events of the namespace, if the underlying store supports events.
+func (p *prefixStore) Subscribe(fn func(Event)) error {
+	return Subscribe(p.s, func(e Event) {
+		if strings.HasPrefix(e.Key, p.prefix) {
+			e.Key = e.Key[len(p.prefix):]
+			fn(e)
+		}
+	})
+}
+
+// GetWithTTL returns ErrTTLNotSupported unless the underlying store implements ExpiringStore.
+func (p *prefixStore) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return GetWithTTL(ctx, p.s, p.prefix+key)
+}
+
+func (p *prefixStore) getManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, []error) {
+	return getManyWithTTL(ctx, p.s, p.keys(keys))
+}
+
+func (p *prefixStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	return GetMany(ctx, p.s, p.keys(keys))
+}
+
+func (p *prefixStore) SetMany(ctx context.Context, entries []Entry) []error {
+	prefixed := make([]Entry, len(entries))
+	for i, e := range entries {
+		prefixed[i] = Entry{Key: p.prefix + e.Key, Data: e.Data, TTL: e.TTL}
+	}
+	return SetMany(ctx, p.s, prefixed)
+}
+
+func (p *prefixStore) DeleteMany(ctx context.Context, keys []string) []error {
+	return DeleteMany(ctx, p.s, p.keys(keys))
+}
+
+func (p *prefixStore) Namespace(name string) Store {
+	return &prefixStore{s: p.s, prefix: p.prefix + name + namespaceSeparator, clearPrefix: p.clearPrefix}
+}
+
+func (p *prefixStore) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = p.prefix + key
+	}
+	return prefixed
+}
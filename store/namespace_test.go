@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/allegro/bigcache/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testNamespaceStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	users := Namespace(s, "users")
+	names := Namespace(s, "names")
+	admins := Namespace(users, "admins")
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{0}))
+	assert.Nil(t, users.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, names.Set(ctx, "a", []byte{2}))
+	assert.Nil(t, admins.Set(ctx, "a", []byte{3}))
+	assert.Nil(t, SetWithTTL(ctx, names, "b", []byte{4}, time.Minute))
+	assert.Nil(t, firstErr(SetMany(ctx, users, []Entry{{Key: "b", Data: []byte{5}}})))
+
+	for want, st := range []Store{s, users, names, admins} {
+		b, err := st.Get(ctx, "a")
+		assert.Nil(t, err)
+		assert.Equal(t, b, []byte{byte(want)})
+	}
+
+	data, errs := GetMany(ctx, users, []string{"a", "b"})
+	assert.Nil(t, firstErr(errs))
+	assert.Equal(t, data, [][]byte{{1}, {5}})
+
+	// delete does not affect other namespaces
+	assert.Nil(t, names.Delete(ctx, "a"))
+	_, err := names.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	b, err := users.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	// clear removes the namespace and the nested namespaces only
+	assert.Nil(t, users.Clear(ctx))
+	for _, st := range []Store{users, admins} {
+		_, err = st.Get(ctx, "a")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+	b, err = names.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{4})
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{0})
+
+	// clear of the root store removes all namespaces
+	assert.Nil(t, s.Clear(ctx))
+	_, err = names.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestNamespace_MapStore(t *testing.T) {
+	testNamespaceStore(t, MapStore(0))
+}
+
+func TestNamespace_BoundedStore(t *testing.T) {
+	testNamespaceStore(t, BoundedStore(BoundedConfig{MaxEntries: 100}))
+}
+
+func TestNamespace_BigcacheStore(t *testing.T) {
+	bc, err := bigcache.NewBigCache(bigcache.DefaultConfig(10 * time.Minute))
+	assert.Nil(t, err)
+	testNamespaceStore(t, BigcacheStore(bc))
+}
+
+func TestNamespace_RedisStore(t *testing.T) {
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	testNamespaceStore(t, RedisStore(rdb))
+}
+
+func TestNamespace_SQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	testNamespaceStore(t, s)
+}
+
+func TestNamespace_TieredStore(t *testing.T) {
+	testNamespaceStore(t, Tiered(MapStore(0), BoundedStore(BoundedConfig{})))
+}
+
+func TestNamespace_TieredStoreWriteBack(t *testing.T) {
+	ctx := context.Background()
+	l2 := MapStore(0)
+	s := TieredStore(TieredConfig{
+		Tiers:     []Tier{{Store: MapStore(0)}, {Store: l2}},
+		WriteMode: WriteBack,
+	})
+	users := Namespace(s, "users")
+
+	assert.Nil(t, users.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.(interface{ Close() error }).Close())
+
+	b, err := Namespace(l2, "users").Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	_, err = l2.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = users.Set(ctx, "b", []byte{2})
+	assert.True(t, errors.Is(err, ErrStoreClosed))
+}
+
+func TestNamespace_CustomStore(t *testing.T) {
+	ctx := context.Background()
+	type customStore struct{ Store }
+	s := customStore{MapStore(0)}
+	users := Namespace(s, "users")
+
+	assert.Nil(t, users.Set(ctx, "a", []byte{1}))
+	_, err := s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	b, err := s.Get(ctx, "users:a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	err = users.Clear(ctx)
+	assert.True(t, errors.Is(err, ErrClearNotSupported))
+}
@@ -3,15 +3,28 @@ package store
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"strings"
+	"time"
 )
 
+// redisScanCount is the number of keys requested by a single SCAN and deleted by a single DEL.
+const redisScanCount = 1000
+
 type redisStore struct {
-	rdb *redis.Client
+	rdb redis.UniversalClient
+	// sharded is set for Cluster and Ring clients, whose keys are spread over the nodes,
+	// so that the multi-key commands are replaced by pipelines of single-key commands.
+	sharded bool
 }
 
-// RedisStore creates a Redis data store.
-func RedisStore(rdb *redis.Client) Store {
-	return &redisStore{rdb}
+// RedisStore creates a Redis data store. The client is a *redis.Client, including the Sentinel failover client,
+// a *redis.ClusterClient or a *redis.Ring. Clear and the namespace clearing are applied to every master node.
+func RedisStore(rdb redis.UniversalClient) Store {
+	switch rdb.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		return &redisStore{rdb: rdb, sharded: true}
+	}
+	return &redisStore{rdb: rdb}
 }
 
 func (r *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
@@ -22,8 +35,58 @@ func (r *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
 	return []byte(v), err
 }
 
+// GetWithTTL reads the entry and its remaining time-to-live in one round trip.
+func (r *redisStore) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	data, ttls, errs := r.getManyWithTTL(ctx, []string{key})
+	return data[0], ttls[0], errs[0]
+}
+
+// getManyWithTTL reads the keys by GET and PTTL commands, which are sent to the nodes of the keys.
+func (r *redisStore) getManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, []error) {
+	data := make([][]byte, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	cmds, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Get(ctx, key)
+			pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if len(cmds) != 2*len(keys) {
+		return data, ttls, repeatErr(err, len(keys))
+	}
+
+	errs := make([]error, len(keys))
+	for i := range keys {
+		b, err := cmds[2*i].(*redis.StringCmd).Bytes()
+		switch {
+		case err == redis.Nil:
+			errs[i] = ErrNotFound
+		case err != nil:
+			errs[i] = err
+		default:
+			switch ttl := cmds[2*i+1].(*redis.DurationCmd).Val(); {
+			case ttl == -1: // the key has no expiration
+				data[i] = b
+			case ttl > 0:
+				data[i], ttls[i] = b, ttl
+			default: // the key expired after it was read
+				errs[i] = ErrNotFound
+			}
+		}
+	}
+	return data, ttls, errs
+}
+
 func (r *redisStore) Set(ctx context.Context, key string, data []byte) error {
-	return r.rdb.Set(ctx, key, data, 0).Err()
+	return r.SetWithTTL(ctx, key, data, 0)
+}
+
+func (r *redisStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.rdb.Set(ctx, key, data, ttl).Err()
 }
 
 func (r *redisStore) Delete(ctx context.Context, key string) error {
@@ -31,5 +94,186 @@ func (r *redisStore) Delete(ctx context.Context, key string) error {
 }
 
 func (r *redisStore) Clear(ctx context.Context) error {
-	return r.rdb.FlushDB(ctx).Err()
+	return r.forEachNode(ctx, func(ctx context.Context, c redis.Cmdable) error {
+		return c.FlushDB(ctx).Err()
+	})
+}
+
+// forEachNode calls the function for every master of a cluster and every shard of a ring concurrently,
+// or for the client itself.
+func (r *redisStore) forEachNode(ctx context.Context, fn func(ctx context.Context, c redis.Cmdable) error) error {
+	node := func(ctx context.Context, c *redis.Client) error {
+		return fn(ctx, c)
+	}
+	switch rdb := r.rdb.(type) {
+	case *redis.ClusterClient:
+		return rdb.ForEachMaster(ctx, node)
+	case *redis.Ring:
+		return rdb.ForEachShard(ctx, node)
+	}
+	return fn(ctx, r.rdb)
+}
+
+func (r *redisStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if r.sharded {
+		return r.getPipelined(ctx, keys)
+	}
+
+	values, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return make([][]byte, len(keys)), repeatErr(err, len(keys))
+	}
+
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			data[i] = []byte(s)
+		} else {
+			errs[i] = ErrNotFound
+		}
+	}
+	return data, errs
+}
+
+// getPipelined reads the keys by GET commands, which are sent to the nodes of the keys.
+func (r *redisStore) getPipelined(ctx context.Context, keys []string) ([][]byte, []error) {
+	cmds, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if len(cmds) != len(keys) {
+		return make([][]byte, len(keys)), repeatErr(err, len(keys))
+	}
+
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, cmd := range cmds {
+		b, err := cmd.(*redis.StringCmd).Bytes()
+		switch {
+		case err == redis.Nil:
+			errs[i] = ErrNotFound
+		case err != nil:
+			errs[i] = err
+		default:
+			data[i] = b
+		}
+	}
+	return data, errs
+}
+
+func (r *redisStore) SetMany(ctx context.Context, entries []Entry) []error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cmds, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, e := range entries {
+			ttl := e.TTL
+			if ttl < 0 {
+				ttl = 0
+			}
+			pipe.Set(ctx, e.Key, e.Data, ttl)
+		}
+		return nil
+	})
+	if len(cmds) != len(entries) {
+		return repeatErr(err, len(entries))
+	}
+
+	errs := make([]error, len(entries))
+	for i, cmd := range cmds {
+		errs[i] = cmd.Err()
+	}
+	return errs
+}
+
+func (r *redisStore) DeleteMany(ctx context.Context, keys []string) []error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if !r.sharded {
+		return repeatErr(r.rdb.Del(ctx, keys...).Err(), len(keys))
+	}
+
+	cmds, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if len(cmds) != len(keys) {
+		return repeatErr(err, len(keys))
+	}
+
+	errs := make([]error, len(keys))
+	for i, cmd := range cmds {
+		errs[i] = cmd.Err()
+	}
+	return errs
+}
+
+func (r *redisStore) Namespace(name string) Store {
+	return newPrefixStore(r, name, r.clearPrefix)
+}
+
+// clearPrefix deletes the keys found by SCAN on every node, so that Redis is not blocked as by KEYS.
+func (r *redisStore) clearPrefix(ctx context.Context, prefix string) error {
+	return r.forEachNode(ctx, func(ctx context.Context, c redis.Cmdable) error {
+		return r.clearNodePrefix(ctx, c, prefix)
+	})
+}
+
+func (r *redisStore) clearNodePrefix(ctx context.Context, c redis.Cmdable, prefix string) error {
+	iter := c.Scan(ctx, 0, escapePattern(prefix)+"*", redisScanCount).Iterator()
+	keys := make([]string, 0, redisScanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == redisScanCount {
+			if err := r.del(ctx, c, keys); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.del(ctx, c, keys)
+	}
+	return nil
+}
+
+// del deletes the keys of a node. The keys of a cluster node belong to different hash slots,
+// so they cannot be deleted by a single DEL.
+func (r *redisStore) del(ctx context.Context, c redis.Cmdable, keys []string) error {
+	if !r.sharded {
+		return c.Del(ctx, keys...).Err()
+	}
+	_, err := c.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+// escapePattern escapes the special characters of the glob-style pattern used by SCAN.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\', '^', '-':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestRedisStore(t *testing.T) {
@@ -58,3 +59,142 @@ func TestRedisStore(t *testing.T) {
 	assert.Nil(t, b)
 	assert.True(t, errors.Is(err, ErrNotFound))
 }
+
+func TestRedisStore_TTL(t *testing.T) {
+	m := miniredis.RunT(t)
+
+	ctx := context.Background()
+	rdb := redis.NewClient(&redis.Options{
+		Addr: m.Addr(),
+		DB:   0,
+	})
+	s := RedisStore(rdb).(TTLStore)
+
+	err := s.SetWithTTL(ctx, "a", []byte{1}, time.Minute)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	m.FastForward(2 * time.Minute)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestRedisStore_GetWithTTL(t *testing.T) {
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+	s := RedisStore(redis.NewClient(&redis.Options{Addr: m.Addr()}))
+	ns := Namespace(s, "ns")
+
+	assert.Nil(t, SetWithTTL(ctx, s, "a", []byte{1}, time.Minute))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+	assert.Nil(t, SetWithTTL(ctx, ns, "a", []byte{3}, time.Hour))
+
+	b, ttl, err := GetWithTTL(ctx, s, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	assert.Equal(t, ttl, time.Minute)
+
+	b, ttl, err = GetWithTTL(ctx, s, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+	assert.Equal(t, ttl, time.Duration(0))
+
+	b, ttl, err = GetWithTTL(ctx, ns, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{3})
+	assert.Equal(t, ttl, time.Hour)
+
+	_, _, err = GetWithTTL(ctx, s, "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	data, ttls, errs := getManyWithTTL(ctx, ns, []string{"a", "missing"})
+	assert.Equal(t, data[0], []byte{3})
+	assert.Equal(t, ttls[0], time.Hour)
+	assert.Nil(t, errs[0])
+	assert.True(t, errors.Is(errs[1], ErrNotFound))
+}
+
+func TestRedisStore_Ring(t *testing.T) {
+	m1, m2 := miniredis.RunT(t), miniredis.RunT(t)
+	ctx := context.Background()
+	rdb := redis.NewRing(&redis.RingOptions{
+		Addrs: map[string]string{"m1": m1.Addr(), "m2": m2.Addr()},
+	})
+	s := RedisStore(rdb)
+	ns := Namespace(s, "ns")
+
+	keys := make([]string, 20)
+	entries := make([]Entry, len(keys))
+	for i := range keys {
+		keys[i] = string(rune('a' + i))
+		entries[i] = Entry{Key: keys[i], Data: []byte{byte(i)}}
+	}
+	for _, err := range SetMany(ctx, s, entries) {
+		assert.Nil(t, err)
+	}
+	for _, err := range SetMany(ctx, ns, entries) {
+		assert.Nil(t, err)
+	}
+	// miniredis does not support COMMAND, so the ring cannot route by the keys, they are added to both shards directly
+	for _, m := range []*miniredis.Miniredis{m1, m2} {
+		assert.Nil(t, m.Set("ns:shard"+m.Addr(), "x"))
+		assert.Nil(t, m.Set("shard"+m.Addr(), "x"))
+	}
+
+	data, errs := GetMany(ctx, s, append(keys, "missing"))
+	for i := range keys {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, data[i], []byte{byte(i)})
+	}
+	assert.True(t, errors.Is(errs[len(keys)], ErrNotFound))
+
+	for _, err := range DeleteMany(ctx, s, keys[:10]) {
+		assert.Nil(t, err)
+	}
+	_, errs = GetMany(ctx, s, keys)
+	for i, err := range errs {
+		assert.Equal(t, errors.Is(err, ErrNotFound), i < 10)
+	}
+
+	// the namespace is cleared on every shard
+	assert.Nil(t, ns.Clear(ctx))
+	assert.Equal(t, len(m1.Keys())+len(m2.Keys()), 12)
+	assert.NotContains(t, m2.Keys(), "ns:shard"+m2.Addr())
+
+	assert.Nil(t, s.Clear(ctx))
+	assert.Empty(t, m1.Keys())
+	assert.Empty(t, m2.Keys())
+}
+
+func TestRedisStore_Cluster(t *testing.T) {
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+	rdb := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{m.Addr()}})
+	s := RedisStore(rdb)
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, Namespace(s, "ns").Set(ctx, "b", []byte{2}))
+	data, errs := GetMany(ctx, s, []string{"a", "ns:b", "c"})
+	assert.Equal(t, data[:2], [][]byte{{1}, {2}})
+	assert.Nil(t, errs[0])
+	assert.True(t, errors.Is(errs[2], ErrNotFound))
+
+	assert.Nil(t, Namespace(s, "ns").Clear(ctx))
+	assert.Equal(t, m.Keys(), []string{"a"})
+	assert.Nil(t, s.Clear(ctx))
+	assert.Empty(t, m.Keys())
+
+	// the universal client of the cluster options
+	assert.Nil(t, RedisStore(redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{m.Addr()}})).Set(ctx, "x", nil))
+}
@@ -0,0 +1,385 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqlBatchSize is the maximum number of keys in a query, it is below the limits of parameters of the databases.
+const sqlBatchSize = 500
+
+// sqlDefaultTable is the table of the entries by default.
+const sqlDefaultTable = "gcache_cache"
+
+// SQLConfig configures a database/sql store.
+type SQLConfig struct {
+	// Dialect generates the statements of the database: SQLite(), Postgres() or MySQL().
+	Dialect Dialect
+	// Table is "gcache_cache" by default. The stores of different tables are independent caches
+	// sharing a database. The name is made of letters, digits and underscores.
+	Table string
+	// CleanupInterval is the period of deleting the expired entries, which are never read but take space.
+	// The expired entries are not deleted in the background if it is zero.
+	CleanupInterval time.Duration
+	// OnError is called for the errors of the background cleanup.
+	OnError func(err error)
+}
+
+// SQL is a store of a database/sql database.
+type SQL interface {
+	TTLStore
+	// DeleteExpired deletes the expired entries of every namespace and returns the number of deleted entries.
+	DeleteExpired(ctx context.Context) (int64, error)
+	// Close stops the background cleanup and closes the prepared statements. The database is not closed.
+	Close() error
+}
+
+// sqlQueries are the statements of a table.
+type sqlQueries struct {
+	d       Dialect
+	table   string
+	get     string
+	set     string
+	del     string
+	clear   string
+	clearNS string
+	expired string
+}
+
+func newSQLQueries(d Dialect, table string) sqlQueries {
+	t, p := d.Quote(table), d.Placeholder
+	key, data, expireAt, ns := d.Quote("key"), d.Quote("data"), d.Quote("expire_at"), d.Quote("namespace")
+	alive := "(" + expireAt + " = 0 OR " + expireAt + " > "
+
+	//goland:noinspection SqlNoDataSourceInspection
+	return sqlQueries{
+		d:     d,
+		table: table,
+		get:   "SELECT " + data + " FROM " + t + " WHERE " + key + " = " + p(1) + " AND " + alive + p(2) + ")",
+		set:   d.Upsert(table),
+		del:   "DELETE FROM " + t + " WHERE " + key + " = " + p(1),
+		clear: "DELETE FROM " + t,
+		// the nested namespaces are in the range from "namespace:" to "namespace;"
+		clearNS: "DELETE FROM " + t + " WHERE " + ns + " = " + p(1) + " OR (" + ns + " > " + p(2) + " AND " + ns + " < " + p(3) + ")",
+		expired: "DELETE FROM " + t + " WHERE " + expireAt + " > 0 AND " + expireAt + " <= " + p(1),
+	}
+}
+
+// getMany returns the statement reading n keys, its last parameter is the current time.
+func (q sqlQueries) getMany(n int) string {
+	d := q.d
+	return "SELECT " + d.Quote("key") + ", " + d.Quote("data") + " FROM " + d.Quote(q.table) +
+		" WHERE " + d.Quote("key") + " IN (" + placeholders(d, n) + ") AND (" +
+		d.Quote("expire_at") + " = 0 OR " + d.Quote("expire_at") + " > " + d.Placeholder(n+1) + ")"
+}
+
+// deleteMany returns the statement deleting n keys.
+func (q sqlQueries) deleteMany(n int) string {
+	d := q.d
+	return "DELETE FROM " + d.Quote(q.table) + " WHERE " + d.Quote("key") + " IN (" + placeholders(d, n) + ")"
+}
+
+// sqlTable is shared by the namespaces of a store.
+type sqlTable struct {
+	q    sqlQueries
+	get  *sql.Stmt
+	set  *sql.Stmt
+	del  *sql.Stmt
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+type sqlStore struct {
+	db        *sql.DB
+	t         *sqlTable
+	cfg       SQLConfig
+	namespace string
+}
+
+// SQLStore creates a store of a database/sql database, the statements are generated by the dialect of the config.
+// The table is created unless it exists.
+func SQLStore(ctx context.Context, db *sql.DB, cfg SQLConfig) (SQL, error) {
+	if cfg.Dialect == nil {
+		return nil, errors.New("sql dialect is not set")
+	}
+	if cfg.Table == "" {
+		cfg.Table = sqlDefaultTable
+	}
+	if err := checkTableName(cfg.Table); err != nil {
+		return nil, err
+	}
+
+	for _, q := range cfg.Dialect.CreateTable(cfg.Table) {
+		if _, err := db.ExecContext(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+	s, err := openSQLStore(ctx, db, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkTableName allows the names made of letters, digits and underscores, which are safe to put in the queries.
+func checkTableName(name string) error {
+	valid := name != "" && name != sqliteSchemaTable && !strings.HasPrefix(strings.ToLower(name), "sqlite_")
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			valid = false
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid table name %q", name)
+	}
+	return nil
+}
+
+// openSQLStore prepares the statements of the existing table and starts the background cleanup.
+func openSQLStore(ctx context.Context, db *sql.DB, cfg SQLConfig) (*sqlStore, error) {
+	q := newSQLQueries(cfg.Dialect, cfg.Table)
+	var stmts [3]*sql.Stmt
+	for i, query := range []string{q.get, q.set, q.del} {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			for _, prepared := range stmts[:i] {
+				_ = prepared.Close()
+			}
+			return nil, err
+		}
+		stmts[i] = stmt
+	}
+
+	s := &sqlStore{
+		db:  db,
+		t:   &sqlTable{q: q, get: stmts[0], set: stmts[1], del: stmts[2], done: make(chan struct{})},
+		cfg: cfg,
+	}
+	if cfg.CleanupInterval > 0 {
+		s.t.wg.Add(1)
+		go s.cleanup()
+	}
+	return s, nil
+}
+
+func (s *sqlStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := s.t.get.QueryRowContext(ctx, s.key(key), time.Now().UnixNano()).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *sqlStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
+}
+
+func (s *sqlStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	_, err := s.t.set.ExecContext(ctx, s.key(key), blob(data), expireAt(ttl), s.namespace)
+	return err
+}
+
+func (s *sqlStore) Delete(ctx context.Context, key string) error {
+	_, err := s.t.del.ExecContext(ctx, s.key(key))
+	return err
+}
+
+// Clear removes the entries of the namespace and its nested namespaces, or all entries from the root store.
+func (s *sqlStore) Clear(ctx context.Context) error {
+	var err error
+	if s.namespace == "" {
+		_, err = s.db.ExecContext(ctx, s.t.q.clear)
+	} else {
+		_, err = s.db.ExecContext(ctx, s.t.q.clearNS, s.namespace, s.namespace+namespaceSeparator, s.namespace+";")
+	}
+	return err
+}
+
+func (s *sqlStore) Namespace(name string) Store {
+	if s.namespace != "" {
+		name = s.namespace + namespaceSeparator + name
+	}
+	return &sqlStore{db: s.db, t: s.t, cfg: s.cfg, namespace: name}
+}
+
+func (s *sqlStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.t.q.expired, time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqlStore) Close() error {
+	var err error
+	s.t.once.Do(func() {
+		close(s.t.done)
+		s.t.wg.Wait()
+		for _, stmt := range []*sql.Stmt{s.t.get, s.t.set, s.t.del} {
+			if e := stmt.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+func (s *sqlStore) cleanup() {
+	defer s.t.wg.Done()
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.t.done:
+			return
+		case <-ticker.C:
+			if _, err := s.DeleteExpired(context.Background()); err != nil && s.cfg.OnError != nil {
+				s.cfg.OnError(err)
+			}
+		}
+	}
+}
+
+// key returns the primary key of the entry, which is unique across namespaces.
+func (s *sqlStore) key(key string) string {
+	if s.namespace == "" {
+		return key
+	}
+	return s.namespace + namespaceSeparator + key
+}
+
+func (s *sqlStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	found := make(map[string][]byte, len(keys))
+
+	for _, chunk := range chunks(keys, sqlBatchSize) {
+		if err := s.getChunk(ctx, chunk, found); err != nil {
+			return data, repeatErr(err, len(keys))
+		}
+	}
+
+	for i, key := range keys {
+		if v, ok := found[s.key(key)]; ok {
+			data[i] = v
+		} else {
+			errs[i] = ErrNotFound
+		}
+	}
+	return data, errs
+}
+
+func (s *sqlStore) SetMany(ctx context.Context, entries []Entry) []error {
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		stmt := tx.StmtContext(ctx, s.t.set)
+		defer stmt.Close()
+
+		for _, e := range entries {
+			if _, err := stmt.ExecContext(ctx, s.key(e.Key), blob(e.Data), expireAt(e.TTL), s.namespace); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return repeatErr(err, len(entries))
+}
+
+func (s *sqlStore) DeleteMany(ctx context.Context, keys []string) []error {
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, chunk := range chunks(keys, sqlBatchSize) {
+			if _, err := tx.ExecContext(ctx, s.t.q.deleteMany(len(chunk)), s.keyArgs(chunk)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return repeatErr(err, len(keys))
+}
+
+func (s *sqlStore) getChunk(ctx context.Context, keys []string, found map[string][]byte) error {
+	args := append(s.keyArgs(keys), time.Now().UnixNano())
+	rows, err := s.db.QueryContext(ctx, s.t.q.getMany(len(keys)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err = rows.Scan(&key, &data); err != nil {
+			return err
+		}
+		found[key] = data
+	}
+	return rows.Err()
+}
+
+// keyArgs returns the primary keys of the entries as query arguments.
+func (s *sqlStore) keyArgs(keys []string) []any {
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = s.key(key)
+	}
+	return args
+}
+
+// blob keeps the nil data apart from the empty data, which is stored as NULL otherwise.
+func blob(data []byte) []byte {
+	if data == nil {
+		return []byte{}
+	}
+	return data
+}
+
+// inTx runs the function in a transaction, which is committed if the function succeeds.
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// chunks splits the keys into parts of at most size keys.
+func chunks(keys []string, size int) [][]string {
+	var parts [][]string
+	for len(keys) > size {
+		parts = append(parts, keys[:size])
+		keys = keys[size:]
+	}
+	if len(keys) > 0 {
+		parts = append(parts, keys)
+	}
+	return parts
+}
+
+// placeholders returns n comma separated query parameters.
+func placeholders(d Dialect, n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(d.Placeholder(i))
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	s, err := SQLStore(ctx, db, SQLConfig{Dialect: SQLite(), Table: "cache"})
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, s.Close())
+	})
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.Set(ctx, "a", []byte{2}))
+	assert.Nil(t, s.SetWithTTL(ctx, "b", []byte{3}, 10*time.Millisecond))
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{3})
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = s.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	n, err := s.DeleteExpired(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, n, int64(1))
+
+	users := Namespace(s, "users")
+	assert.Nil(t, users.Set(ctx, "a", []byte{4}))
+	errs := SetMany(ctx, users, []Entry{{Key: "b", Data: []byte{5}}, {Key: "c", Data: nil}})
+	assert.Equal(t, errs, []error{nil, nil})
+
+	data, errs := GetMany(ctx, users, []string{"a", "b", "c", "d"})
+	assert.Equal(t, data, [][]byte{{4}, {5}, {}, nil})
+	assert.Equal(t, errs, []error{nil, nil, nil, ErrNotFound})
+
+	errs = DeleteMany(ctx, users, []string{"a", "b"})
+	assert.Equal(t, errs, []error{nil, nil})
+	_, err = users.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Nil(t, users.Clear(ctx))
+	_, err = users.Get(ctx, "c")
+	assert.ErrorIs(t, err, ErrNotFound)
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+
+	assert.Nil(t, s.Delete(ctx, "a"))
+	_, err = s.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLStore_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	var failed int32
+	s, err := SQLStore(ctx, db, SQLConfig{
+		Dialect:         SQLite(),
+		CleanupInterval: 5 * time.Millisecond,
+		OnError:         func(error) { atomic.AddInt32(&failed, 1) },
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.SetWithTTL(ctx, "a", []byte{1}, time.Millisecond))
+	assert.Nil(t, s.Set(ctx, "b", []byte{2}))
+
+	assert.Eventually(t, func() bool {
+		var n int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM gcache_cache;`).Scan(&n)
+		return err == nil && n == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, atomic.LoadInt32(&failed), int32(0))
+
+	assert.Nil(t, s.Close())
+	// repeated close must be safe
+	assert.Nil(t, s.Close())
+}
+
+func TestSQLStore_Config(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	_, err := SQLStore(ctx, db, SQLConfig{})
+	assert.NotNil(t, err)
+	_, err = SQLStore(ctx, db, SQLConfig{Dialect: SQLite(), Table: "cache; DROP TABLE gcache_schema"})
+	assert.NotNil(t, err)
+
+	// the SQLite store and the SQL store with the SQLite dialect share the table
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+
+	g, err := SQLStore(ctx, db, SQLConfig{Dialect: SQLite()})
+	assert.Nil(t, err)
+	b, err := g.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	assert.Nil(t, g.Close())
+}
+
+// TestSQLStore_PostgresQueries runs the statements of the PostgreSQL dialect, which SQLite understands
+// except for the table definition, against SQLite.
+func TestSQLStore_PostgresQueries(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	_, err := SQLStore(ctx, db, SQLConfig{Dialect: SQLite()})
+	assert.Nil(t, err)
+	s, err := openSQLStore(ctx, db, SQLConfig{Dialect: Postgres(), Table: sqlDefaultTable})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, s.SetWithTTL(ctx, "a", []byte{2}, time.Minute))
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+
+	ns := s.Namespace("users")
+	errs := SetMany(ctx, ns, []Entry{{Key: "a", Data: []byte{3}}, {Key: "b", Data: []byte{4}}})
+	assert.Equal(t, errs, []error{nil, nil})
+	data, errs := GetMany(ctx, ns, []string{"a", "b", "c"})
+	assert.Equal(t, data, [][]byte{{3}, {4}, nil})
+	assert.Equal(t, errs, []error{nil, nil, ErrNotFound})
+	assert.Equal(t, DeleteMany(ctx, ns, []string{"a"}), []error{nil})
+	assert.Nil(t, ns.Clear(ctx))
+	_, err = ns.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Nil(t, s.Delete(ctx, "a"))
+	_, err = s.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.DeleteExpired(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+}
@@ -3,88 +3,65 @@ package store
 import (
 	"context"
 	"database/sql"
-	"encoding/hex"
 	"errors"
 	_ "github.com/mattn/go-sqlite3" // Import go-sqlite3 library
+	"strings"
 )
 
-const tableAlreadyExists = "table gcache_cache already exists"
-
-type sqliteStore struct {
-	db *sql.DB
-}
-
-// SQLiteStore creates a SQLite data store.
-func SQLiteStore(ctx context.Context, db *sql.DB) (Store, error) {
-	err := createTable(ctx, db)
-	if err != nil {
-		return nil, err
-	}
-	return &sqliteStore{db}, nil
+// SQLiteConfig configures a SQLite data store.
+type SQLiteConfig struct {
+	// Table is "gcache_cache" by default. The stores of different tables are independent caches
+	// sharing a database file. The name is made of letters, digits and underscores.
+	Table string
+	// WAL switches the database to the write-ahead log journal mode, so the readers do not block the writer.
+	// The journal mode is kept by the database file.
+	WAL bool
 }
 
-func (s sqliteStore) Get(ctx context.Context, key string) ([]byte, error) {
-	var hexString string
-	//goland:noinspection SqlNoDataSourceInspection
-	row := s.db.QueryRowContext(ctx, "SELECT data FROM gcache_cache WHERE key = ?", key)
-	err := row.Scan(&hexString)
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNotFound
-		}
-		return nil, err
+// SQLiteDSN returns the data source name of the database file with the synchronous pragma, which is
+// "OFF", "NORMAL", "FULL" or "EXTRA". The pragma is a setting of a connection, so it is set by the driver
+// on every connection of the pool rather than by the store. The path may be a "file:" URI with parameters.
+func SQLiteDSN(path, synchronous string) (string, error) {
+	mode := strings.ToUpper(synchronous)
+	switch mode {
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		return "", errors.New("unknown synchronous mode: " + synchronous)
 	}
-
-	v, err := hex.DecodeString(hexString)
-	if err != nil {
-		return nil, err
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
 	}
-	return v, nil
+	return path + sep + "_sync=" + mode, nil
 }
 
-func (s *sqliteStore) Set(ctx context.Context, key string, data []byte) error {
-	hexString := hex.EncodeToString(data)
-	//goland:noinspection SqlNoDataSourceInspection
-	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO gcache_cache (key, data) VALUES (?, ?)", key, hexString)
-	if err != nil {
-		return err
-	}
-	return nil
+// SQLiteStore creates a SQLite data store.
+func SQLiteStore(ctx context.Context, db *sql.DB) (Store, error) {
+	return SQLiteStoreWithConfig(ctx, db, SQLiteConfig{})
 }
 
-func (s *sqliteStore) Delete(ctx context.Context, key string) error {
-	//goland:noinspection SqlNoDataSourceInspection
-	_, err := s.db.ExecContext(ctx, "DELETE FROM gcache_cache WHERE key = ?", key)
-	if err != nil {
-		return err
+// SQLiteStoreWithConfig creates a SQLite data store with the table and the journal mode of the config.
+// The table is created if it does not exist, or upgraded by the forward migrations if it was written
+// by the previous versions, which is recorded in the gcache_schema table. The store implements SQL.
+func SQLiteStoreWithConfig(ctx context.Context, db *sql.DB, cfg SQLiteConfig) (Store, error) {
+	if cfg.Table == "" {
+		cfg.Table = sqlDefaultTable
 	}
-	return nil
-}
-
-func (s *sqliteStore) Clear(ctx context.Context) error {
-	//goland:noinspection SqlNoDataSourceInspection
-	_, err := s.db.ExecContext(ctx, "DELETE FROM gcache_cache")
-	if err != nil {
-		return err
+	if err := checkTableName(cfg.Table); err != nil {
+		return nil, err
 	}
-	return nil
-}
-
-func createTable(ctx context.Context, db *sql.DB) error {
-	q := `CREATE TABLE gcache_cache ("key" VARCHAR(64) NOT NULL PRIMARY KEY, "data" TEXT);`
-
-	statement, err := db.PrepareContext(ctx, q)
-	if err != nil {
-		if err.Error() == tableAlreadyExists {
-			return nil
+	if cfg.WAL {
+		//goland:noinspection SqlNoDataSourceInspection
+		if _, err := db.ExecContext(ctx, "PRAGMA journal_mode = WAL;"); err != nil {
+			return nil, err
 		}
-		return err
 	}
-
-	_, err = statement.ExecContext(ctx)
+	if err := createTable(ctx, db, cfg.Table); err != nil {
+		return nil, err
+	}
+	s, err := openSQLStore(ctx, db, SQLConfig{Dialect: SQLite(), Table: cfg.Table})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return s, nil
 }
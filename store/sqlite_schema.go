@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqliteSchemaTable keeps the schema versions of the tables of the SQLite stores in a database.
+const sqliteSchemaTable = "gcache_schema"
+
+// sqliteMigration upgrades the table from the previous schema version.
+type sqliteMigration func(ctx context.Context, tx *sql.Tx, table string) error
+
+// sqliteMigrations upgrade the tables written by the previous versions, the migration i upgrades the schema
+// version i+1 to i+2. The tables created anew get the latest schema at once.
+var sqliteMigrations = []sqliteMigration{
+	// 2: the entries expire
+	func(ctx context.Context, tx *sql.Tx, table string) error {
+		return addColumn(ctx, tx, table, "expire_at", "INTEGER NOT NULL DEFAULT 0")
+	},
+	// 3: the entries belong to namespaces
+	func(ctx context.Context, tx *sql.Tx, table string) error {
+		return addColumn(ctx, tx, table, "namespace", "TEXT NOT NULL DEFAULT ''")
+	},
+	// 4: the data is BLOB instead of hex text
+	migrateToBlob,
+}
+
+// sqliteSchemaVersion is the version of the latest schema.
+var sqliteSchemaVersion = len(sqliteMigrations) + 1
+
+// sqliteTable returns the statement creating the table of the entries.
+func sqliteTable(name string) string {
+	return `CREATE TABLE IF NOT EXISTS "` + name + `" ("key" TEXT NOT NULL PRIMARY KEY, "data" BLOB,
+		"expire_at" INTEGER NOT NULL DEFAULT 0, "namespace" TEXT NOT NULL DEFAULT '');`
+}
+
+// createTable creates the table or upgrades it to the latest schema, and deletes the expired entries.
+func createTable(ctx context.Context, db *sql.DB, table string) error {
+	err := inTx(ctx, db, func(tx *sql.Tx) error {
+		//goland:noinspection SqlNoDataSourceInspection
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+sqliteSchemaTable+
+			` ("table" TEXT NOT NULL PRIMARY KEY, "version" INTEGER NOT NULL);`)
+		if err != nil {
+			return err
+		}
+
+		version, err := schemaVersion(ctx, tx, table)
+		switch {
+		case err != nil:
+			return err
+		case version > sqliteSchemaVersion:
+			return fmt.Errorf("table %s has schema version %d, the latest supported version is %d",
+				table, version, sqliteSchemaVersion)
+		case version == 0:
+			version = sqliteSchemaVersion
+		}
+		for ; version < sqliteSchemaVersion; version++ {
+			if err = sqliteMigrations[version-1](ctx, tx, table); err != nil {
+				return err
+			}
+		}
+
+		// the table is created, or its indexes are created again after the migrations
+		for _, q := range SQLite().CreateTable(table) {
+			if _, err = tx.ExecContext(ctx, q); err != nil {
+				return err
+			}
+		}
+		//goland:noinspection SqlNoDataSourceInspection
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO `+sqliteSchemaTable+` ("table", "version") VALUES (?, ?);`,
+			table, version)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// the entries expired while the database was not used are never read again
+	//goland:noinspection SqlNoDataSourceInspection
+	_, err = db.ExecContext(ctx, `DELETE FROM "`+table+`" WHERE "expire_at" > 0 AND "expire_at" <= ?;`, time.Now().UnixNano())
+	return err
+}
+
+// schemaVersion returns the schema version of the table, or zero if there is no table. The version
+// of the tables created before the versions were kept is told by their columns.
+func schemaVersion(ctx context.Context, tx *sql.Tx, table string) (int, error) {
+	var version int
+	//goland:noinspection SqlNoDataSourceInspection
+	err := tx.QueryRowContext(ctx, `SELECT "version" FROM `+sqliteSchemaTable+` WHERE "table" = ?;`, table).Scan(&version)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return version, err
+	}
+
+	//goland:noinspection SqlNoDataSourceInspection
+	rows, err := tx.QueryContext(ctx, `SELECT "name", "type" FROM pragma_table_info(?);`, table)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err = rows.Scan(&name, &typ); err != nil {
+			return 0, err
+		}
+		columns[name] = strings.ToUpper(typ)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case len(columns) == 0:
+		return 0, nil
+	case columns["data"] == "BLOB":
+		return 4, nil
+	case columns["namespace"] != "":
+		return 3, nil
+	case columns["expire_at"] != "":
+		return 2, nil
+	}
+	return 1, nil
+}
+
+func addColumn(ctx context.Context, tx *sql.Tx, table, name, definition string) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE "`+table+`" ADD COLUMN "`+name+`" `+definition+`;`)
+	return err
+}
+
+// migrateToBlob copies the entries of the table written by the previous versions, whose data is hex text,
+// to a table with the BLOB data column, which replaces it. The entries whose data is not hex are dropped.
+func migrateToBlob(ctx context.Context, tx *sql.Tx, table string) error {
+	migrated := table + "_migration"
+	if _, err := tx.ExecContext(ctx, sqliteTable(migrated)); err != nil {
+		return err
+	}
+	//goland:noinspection SqlNoDataSourceInspection
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO "`+migrated+`" (key, data, expire_at, namespace) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	// the entries are read in chunks, so the table does not have to fit in memory
+	var (
+		last string
+		read bool
+	)
+	for {
+		entries, err := hexChunk(ctx, tx, table, last, !read)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, e := range entries {
+			data, err := hex.DecodeString(e.data)
+			if err != nil {
+				continue
+			}
+			if _, err = insert.ExecContext(ctx, e.key, blob(data), e.expireAt, e.namespace); err != nil {
+				return err
+			}
+		}
+		last, read = entries[len(entries)-1].key, true
+	}
+
+	// the indexes are dropped along with the table
+	//goland:noinspection SqlNoDataSourceInspection
+	for _, q := range []string{`DROP TABLE "` + table + `";`, `ALTER TABLE "` + migrated + `" RENAME TO "` + table + `";`} {
+		if _, err = tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hexEntry is a row of the table written by the previous versions.
+type hexEntry struct {
+	key, data, namespace string
+	expireAt             int64
+}
+
+// hexChunk reads the rows following the key, or the first rows.
+func hexChunk(ctx context.Context, tx *sql.Tx, table, after string, first bool) ([]hexEntry, error) {
+	//goland:noinspection SqlNoDataSourceInspection
+	rows, err := tx.QueryContext(ctx, `SELECT key, COALESCE(data, ''), expire_at, namespace FROM "`+table+`"
+		WHERE ? OR key > ? ORDER BY key LIMIT ?`, first, after, sqlBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []hexEntry
+	for rows.Next() {
+		var e hexEntry
+		if err = rows.Scan(&e.key, &e.data, &e.expireAt, &e.namespace); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+	return db
+}
+
+func schemaVersionOf(t *testing.T, db *sql.DB, table string) int {
+	var version int
+	err := db.QueryRow(`SELECT "version" FROM gcache_schema WHERE "table" = ?;`, table).Scan(&version)
+	assert.Nil(t, err)
+	return version
+}
+
+func TestSQLiteStore_MigrateHex(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	// the table created by the previous versions keeps the data as hex text
+	_, err := db.ExecContext(ctx, `CREATE TABLE gcache_cache ("key" VARCHAR(64) NOT NULL PRIMARY KEY, "data" TEXT,
+		"expire_at" INTEGER NOT NULL DEFAULT 0, "namespace" TEXT NOT NULL DEFAULT '');`)
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO gcache_cache (key, data, expire_at, namespace) VALUES
+		('', '0a', 0, ''), ('a', '010203', 0, ''), ('b', '', 0, ''), ('c', 'not hex', 0, ''),
+		('d', '04', ?, ''), ('users:e', '05', 0, 'users')`, time.Now().Add(-time.Minute).UnixNano())
+	assert.Nil(t, err)
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+
+	var dataType string
+	err = db.QueryRowContext(ctx, `SELECT "type" FROM pragma_table_info('gcache_cache') WHERE "name" = 'data';`).Scan(&dataType)
+	assert.Nil(t, err)
+	assert.Equal(t, dataType, "BLOB")
+	assert.Equal(t, schemaVersionOf(t, db, "gcache_cache"), sqliteSchemaVersion)
+
+	data, errs := s.(BatchStore).GetMany(ctx, []string{"", "a", "b", "c", "d"})
+	assert.Equal(t, data, [][]byte{{0x0a}, {1, 2, 3}, {}, nil, nil})
+	assert.Equal(t, errs, []error{nil, nil, nil, ErrNotFound, ErrNotFound})
+
+	b, err := Namespace(s, "users").Get(ctx, "e")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{5})
+
+	// the expired entries are deleted
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM gcache_cache;`).Scan(&n)
+	assert.Nil(t, err)
+	assert.Equal(t, n, 4)
+
+	// repeated migration must be safe
+	_, err = SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+}
+
+func TestSQLiteStore_Tables(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	users, err := SQLiteStoreWithConfig(ctx, db, SQLiteConfig{Table: "users"})
+	assert.Nil(t, err)
+	orders, err := SQLiteStoreWithConfig(ctx, db, SQLiteConfig{Table: "orders"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, users.Set(ctx, "a", []byte{1}))
+	assert.Nil(t, orders.Set(ctx, "a", []byte{2}))
+
+	b, err := users.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	// the caches are cleared independently
+	assert.Nil(t, orders.Clear(ctx))
+	_, err = orders.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	b, err = users.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	assert.Equal(t, schemaVersionOf(t, db, "users"), sqliteSchemaVersion)
+	assert.Equal(t, schemaVersionOf(t, db, "orders"), sqliteSchemaVersion)
+
+	// repeated creation must be safe
+	_, err = SQLiteStoreWithConfig(ctx, db, SQLiteConfig{Table: "users"})
+	assert.Nil(t, err)
+	b, err = users.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+}
+
+func TestSQLiteStore_TableName(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	for _, name := range []string{"1users", "users;", `users"`, "gcache_schema", "sqlite_master", "my table"} {
+		_, err := SQLiteStoreWithConfig(ctx, db, SQLiteConfig{Table: name})
+		assert.NotNil(t, err, name)
+	}
+	_, err := SQLiteStoreWithConfig(ctx, db, SQLiteConfig{Table: "_users_2"})
+	assert.Nil(t, err)
+}
+
+func TestSQLiteStore_SchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	// the table created before the versions were kept already has the latest columns
+	_, err := db.ExecContext(ctx, `CREATE TABLE gcache_cache ("key" TEXT NOT NULL PRIMARY KEY, "data" BLOB,
+		"expire_at" INTEGER NOT NULL DEFAULT 0, "namespace" TEXT NOT NULL DEFAULT '');`)
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO gcache_cache (key, data) VALUES ('a', X'0102');`)
+	assert.Nil(t, err)
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	assert.Equal(t, schemaVersionOf(t, db, "gcache_cache"), sqliteSchemaVersion)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2})
+
+	// the table written by a newer version is not touched
+	_, err = db.ExecContext(ctx, `UPDATE gcache_schema SET "version" = ? WHERE "table" = 'gcache_cache';`, sqliteSchemaVersion+1)
+	assert.Nil(t, err)
+	_, err = SQLiteStore(ctx, db)
+	assert.NotNil(t, err)
+}
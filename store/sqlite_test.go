@@ -3,17 +3,20 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"github.com/stretchr/testify/assert"
-	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSQLiteStore_Context(t *testing.T) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
-	db, err := sql.Open("sqlite3", "test.db")
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
 	assert.Nil(t, err)
 	s, err := SQLiteStore(ctx, db)
 	assert.Nil(t, err)
@@ -49,11 +52,7 @@ func TestSQLiteStore_Context(t *testing.T) {
 }
 
 func TestSQLiteStore(t *testing.T) {
-	filename := "test.db"
-	if _, err := os.Stat(filename); err == nil {
-		err := os.Remove(filename)
-		assert.Nil(t, err)
-	}
+	filename := filepath.Join(t.TempDir(), "test.db")
 
 	ctx := context.Background()
 	db, err := sql.Open("sqlite3", filename)
@@ -99,3 +98,199 @@ func TestSQLiteStore(t *testing.T) {
 	assert.Nil(t, b)
 	assert.True(t, errors.Is(err, ErrNotFound))
 }
+
+func TestSQLiteStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+	ts := s.(TTLStore)
+
+	err = ts.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = ts.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestSQLiteStore_UpgradeTable(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+
+	// the table created by the previous versions has no expiration column
+	_, err = db.ExecContext(ctx, `CREATE TABLE gcache_cache ("key" VARCHAR(64) NOT NULL PRIMARY KEY, "data" TEXT);`)
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO gcache_cache (key, data) VALUES ('a', '010203');`)
+	assert.Nil(t, err)
+
+	s, err := SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+
+	err = s.(TTLStore).SetWithTTL(ctx, "b", []byte{4}, time.Minute)
+	assert.Nil(t, err)
+	err = Namespace(s, "users").Set(ctx, "c", []byte{5})
+	assert.Nil(t, err)
+	err = Namespace(s, "users").Clear(ctx)
+	assert.Nil(t, err)
+
+	// repeated upgrade must be safe
+	_, err = SQLiteStore(ctx, db)
+	assert.Nil(t, err)
+}
+
+func TestSQLiteStore_Config(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, db.Close())
+	})
+
+	s, err := SQLiteStoreWithConfig(ctx, db, SQLiteConfig{WAL: true})
+	assert.Nil(t, err)
+
+	var mode string
+	err = db.QueryRowContext(ctx, "PRAGMA journal_mode;").Scan(&mode)
+	assert.Nil(t, err)
+	assert.Equal(t, mode, "wal")
+
+	err = s.Set(ctx, "a", nil)
+	assert.Nil(t, err)
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{})
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	for _, name := range []string{path, "file:" + path + "?cache=shared&mode=rwc"} {
+		dsn, err := SQLiteDSN(name, "normal")
+		assert.Nil(t, err)
+		db, err := sql.Open("sqlite3", dsn)
+		assert.Nil(t, err)
+		_, err = SQLiteStore(ctx, db)
+		assert.Nil(t, err, dsn)
+
+		// the pragma is set on every connection of the pool
+		conns := make([]*sql.Conn, 2)
+		for i := range conns {
+			conns[i], err = db.Conn(ctx)
+			assert.Nil(t, err)
+			var synchronous int
+			err = conns[i].QueryRowContext(ctx, "PRAGMA synchronous;").Scan(&synchronous)
+			assert.Nil(t, err)
+			assert.Equal(t, synchronous, 1, dsn) // NORMAL
+		}
+		for _, conn := range conns {
+			assert.Nil(t, conn.Close())
+		}
+		assert.Nil(t, db.Close())
+	}
+
+	_, err := SQLiteDSN("test.db", "NORMAL&_journal_mode=DELETE")
+	assert.NotNil(t, err)
+}
+
+// hexSQLiteStore is the previous implementation, which kept the data as hex text and prepared every query.
+type hexSQLiteStore struct {
+	db *sql.DB
+}
+
+func (s *hexSQLiteStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var hexString string
+	row := s.db.QueryRowContext(ctx, "SELECT data FROM gcache_cache WHERE key = ? AND (expire_at = 0 OR expire_at > ?)",
+		key, time.Now().UnixNano())
+	if err := row.Scan(&hexString); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(hexString)
+}
+
+func (s *hexSQLiteStore) Set(ctx context.Context, key string, data []byte) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO gcache_cache (key, data, expire_at, namespace) VALUES (?, ?, ?, ?)",
+		key, hex.EncodeToString(data), 0, "")
+	return err
+}
+
+func (s *hexSQLiteStore) Delete(context.Context, string) error { return nil }
+func (s *hexSQLiteStore) Clear(context.Context) error          { return nil }
+
+func benchmarkSQLiteStore(b *testing.B, open func(ctx context.Context, db *sql.DB) (Store, error), synchronous string) {
+	ctx := context.Background()
+	dsn := filepath.Join(b.TempDir(), "test.db")
+	if synchronous != "" {
+		var err error
+		dsn, err = SQLiteDSN(dsn, synchronous)
+		assert.Nil(b, err)
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	assert.Nil(b, err)
+	b.Cleanup(func() {
+		assert.Nil(b, db.Close())
+	})
+	s, err := open(ctx, db)
+	assert.Nil(b, err)
+
+	data := make([]byte, 1024)
+	for k := 0; k < 1000; k++ {
+		assert.Nil(b, s.Set(ctx, strconv.Itoa(k), data))
+	}
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for k := 0; k < b.N; k++ {
+			_, _ = s.Get(ctx, strconv.Itoa(k%1000))
+		}
+	})
+	b.Run("Set", func(b *testing.B) {
+		b.ReportAllocs()
+		for k := 0; k < b.N; k++ {
+			_ = s.Set(ctx, strconv.Itoa(k%1000), data)
+		}
+	})
+}
+
+func BenchmarkSQLiteStore_Hex(b *testing.B) {
+	benchmarkSQLiteStore(b, func(ctx context.Context, db *sql.DB) (Store, error) {
+		_, err := db.ExecContext(ctx, `CREATE TABLE gcache_cache ("key" VARCHAR(64) NOT NULL PRIMARY KEY, "data" TEXT,
+			"expire_at" INTEGER NOT NULL DEFAULT 0, "namespace" TEXT NOT NULL DEFAULT '');`)
+		return &hexSQLiteStore{db: db}, err
+	}, "")
+}
+
+func BenchmarkSQLiteStore_Blob(b *testing.B) {
+	benchmarkSQLiteStore(b, SQLiteStore, "")
+}
+
+func BenchmarkSQLiteStore_BlobWAL(b *testing.B) {
+	benchmarkSQLiteStore(b, func(ctx context.Context, db *sql.DB) (Store, error) {
+		return SQLiteStoreWithConfig(ctx, db, SQLiteConfig{WAL: true})
+	}, "NORMAL")
+}
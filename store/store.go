@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 // Store is the interface implemented by types that can be data storage for cache.
@@ -13,5 +14,107 @@ type Store interface {
 	Clear(ctx context.Context) error
 }
 
+// TTLStore is the interface implemented by stores that can expire entries after the given time-to-live.
+// A zero or negative ttl means that the entry never expires.
+type TTLStore interface {
+	Store
+	SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// ExpiringStore is the interface implemented by stores that report the remaining time-to-live of the entries.
+type ExpiringStore interface {
+	TTLStore
+	// GetWithTTL returns the data and the remaining time-to-live of the entry, which is zero if it never expires.
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
 // ErrNotFound indicates that key not found in the store.
 var ErrNotFound = errors.New("key not found")
+
+// ErrTTLNotSupported indicates that the store cannot expire entries.
+var ErrTTLNotSupported = errors.New("store does not support ttl")
+
+// SetWithTTL sets data into the store with the given time-to-live.
+// The store must implement TTLStore unless ttl is zero or negative.
+func SetWithTTL(ctx context.Context, s Store, key string, data []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return s.Set(ctx, key, data)
+	}
+	if ts, ok := s.(TTLStore); ok {
+		return ts.SetWithTTL(ctx, key, data, ttl)
+	}
+	return ErrTTLNotSupported
+}
+
+// GetWithTTL gets the data and the remaining time-to-live of the entry, which is zero if it never expires.
+// The store must implement ExpiringStore.
+func GetWithTTL(ctx context.Context, s Store, key string) ([]byte, time.Duration, error) {
+	if es, ok := s.(ExpiringStore); ok {
+		return es.GetWithTTL(ctx, key)
+	}
+	return nil, 0, ErrTTLNotSupported
+}
+
+// manyExpiringStore is implemented by the stores that read the remaining time-to-live of many keys at once.
+type manyExpiringStore interface {
+	getManyWithTTL(ctx context.Context, keys []string) ([][]byte, []time.Duration, []error)
+}
+
+// unknownTTL is the remaining time-to-live of the entries of the stores that do not report it.
+const unknownTTL time.Duration = -1
+
+// getWithTTL gets the data and the remaining time-to-live, which is unknownTTL if the store does not report it.
+func getWithTTL(ctx context.Context, s Store, key string) ([]byte, time.Duration, error) {
+	data, ttl, err := GetWithTTL(ctx, s, key)
+	if errors.Is(err, ErrTTLNotSupported) {
+		data, err = s.Get(ctx, key)
+		return data, unknownTTL, err
+	}
+	return data, ttl, err
+}
+
+// getManyWithTTL gets the data and the remaining time-to-live of many keys, see getWithTTL.
+func getManyWithTTL(ctx context.Context, s Store, keys []string) ([][]byte, []time.Duration, []error) {
+	if es, ok := s.(manyExpiringStore); ok {
+		return es.getManyWithTTL(ctx, keys)
+	}
+
+	data := make([][]byte, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	if _, ok := s.(ExpiringStore); ok {
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			data[i], ttls[i], errs[i] = getWithTTL(ctx, s, key)
+		}
+		return data, ttls, errs
+	}
+
+	data, errs := GetMany(ctx, s, keys)
+	for i := range ttls {
+		ttls[i] = unknownTTL
+	}
+	return data, ttls, errs
+}
+
+// expireAt returns the expiration time in Unix nanoseconds, or zero if ttl means no expiration.
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// remaining returns the time-to-live left until the expiration time returned by expireAt, or zero if there is none.
+// It reports false if the expiration time has passed.
+func remaining(at int64) (time.Duration, bool) {
+	if at == 0 {
+		return 0, true
+	}
+	ttl := time.Duration(at - time.Now().UnixNano())
+	return ttl, ttl > 0
+}
+
+// expired reports whether the expiration time returned by expireAt has passed.
+func expired(at int64) bool {
+	return at != 0 && at <= time.Now().UnixNano()
+}
@@ -2,47 +2,238 @@ package store
 
 import (
 	"context"
+	"strings"
 	"sync"
+	"time"
 )
 
+type mapEntry struct {
+	data     []byte
+	expireAt int64
+}
+
+// mapSweepMin is the least number of writes between the sweeps of the expired entries.
+const mapSweepMin = 64
+
+// mapSpaces holds a map per namespace, the root namespace is an empty string.
+type mapSpaces struct {
+	mx        sync.RWMutex
+	m         map[string]map[string]mapEntry
+	size      int
+	listeners map[string][]func(Event) // subscribers per namespace
+	writes    int                      // writes since the previous sweep
+	sweepAt   int                      // writes that start the next sweep
+}
+
 type mapStore struct {
-	mx sync.RWMutex
-	m  map[string][]byte
+	*mapSpaces
+	ns string
 }
 
 // MapStore creates a store that is like a Go map but is safe for concurrent use by multiple goroutines.
+// The expired entries are deleted when they are read, and by a sweep of all entries once the writes since
+// the previous sweep outnumber the entries, so the entries that are never read again do not pile up.
 func MapStore(size int) Store {
-	m := make(map[string][]byte, size)
-	return &mapStore{m: m}
+	spaces := &mapSpaces{m: make(map[string]map[string]mapEntry), size: size, listeners: make(map[string][]func(Event)),
+		sweepAt: mapSweepMin}
+	spaces.m[""] = make(map[string]mapEntry, size)
+	return &mapStore{mapSpaces: spaces}
+}
+
+func (s *mapStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, _, err := s.GetWithTTL(ctx, key)
+	return data, err
 }
 
-func (s *mapStore) Get(_ context.Context, key string) ([]byte, error) {
+func (s *mapStore) GetWithTTL(_ context.Context, key string) ([]byte, time.Duration, error) {
 	s.mx.RLock()
-	defer s.mx.RUnlock()
-	v, ok := s.m[key]
+	e, ok := s.m[s.ns][key]
+	s.mx.RUnlock()
+
 	if !ok {
-		return nil, ErrNotFound
+		return nil, 0, ErrNotFound
 	}
-	return v, nil
+	ttl, ok := remaining(e.expireAt)
+	if !ok {
+		s.deleteExpired([]string{key})
+		return nil, 0, ErrNotFound
+	}
+	return e.data, ttl, nil
+}
+
+func (s *mapStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
 }
 
-func (s *mapStore) Set(_ context.Context, key string, data []byte) error {
+func (s *mapStore) SetWithTTL(_ context.Context, key string, data []byte, ttl time.Duration) error {
 	s.mx.Lock()
-	defer s.mx.Unlock()
-	s.m[key] = data
+	s.space()[key] = mapEntry{data: data, expireAt: expireAt(ttl)}
+	events := s.written(1)
+	s.mx.Unlock()
+	events.notify()
 	return nil
 }
 
 func (s *mapStore) Delete(_ context.Context, key string) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	delete(s.m, key)
+	delete(s.m[s.ns], key)
 	return nil
 }
 
+// Clear removes the entries of the namespace and its nested namespaces, or all entries from the root store.
 func (s *mapStore) Clear(_ context.Context) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	s.m = make(map[string][]byte)
+	if s.ns == "" {
+		s.m = map[string]map[string]mapEntry{"": make(map[string]mapEntry, s.size)}
+		return nil
+	}
+
+	for ns := range s.m {
+		if ns == s.ns || strings.HasPrefix(ns, s.ns+namespaceSeparator) {
+			delete(s.m, ns)
+		}
+	}
 	return nil
 }
+
+func (s *mapStore) Namespace(name string) Store {
+	if s.ns != "" {
+		name = s.ns + namespaceSeparator + name
+	}
+	return &mapStore{mapSpaces: s.mapSpaces, ns: name}
+}
+
+func (s *mapStore) GetMany(_ context.Context, keys []string) ([][]byte, []error) {
+	data := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	var expiredKeys []string
+
+	s.mx.RLock()
+	for i, key := range keys {
+		e, ok := s.m[s.ns][key]
+		switch {
+		case !ok:
+			errs[i] = ErrNotFound
+		case expired(e.expireAt):
+			errs[i] = ErrNotFound
+			expiredKeys = append(expiredKeys, key)
+		default:
+			data[i] = e.data
+		}
+	}
+	s.mx.RUnlock()
+
+	if expiredKeys != nil {
+		s.deleteExpired(expiredKeys)
+	}
+	return data, errs
+}
+
+func (s *mapStore) SetMany(_ context.Context, entries []Entry) []error {
+	s.mx.Lock()
+	m := s.space()
+	for _, e := range entries {
+		m[e.Key] = mapEntry{data: e.Data, expireAt: expireAt(e.TTL)}
+	}
+	events := s.written(len(entries))
+	s.mx.Unlock()
+	events.notify()
+	return make([]error, len(entries))
+}
+
+func (s *mapStore) DeleteMany(_ context.Context, keys []string) []error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, key := range keys {
+		delete(s.m[s.ns], key)
+	}
+	return make([]error, len(keys))
+}
+
+// deleteExpired deletes the expired entries and notifies the subscribers of the namespace.
+func (s *mapStore) deleteExpired(keys []string) {
+	var events expiredEvents
+	s.mx.Lock()
+	for _, key := range keys {
+		// the entry may have been overwritten since it was read
+		if e, ok := s.m[s.ns][key]; ok && expired(e.expireAt) {
+			delete(s.m[s.ns], key)
+			events = events.add(s.ns, Event{Kind: Expired, Key: key, Data: e.data}, s.listeners)
+		}
+	}
+	s.mx.Unlock()
+	events.notify()
+}
+
+// written counts the writes and sweeps the expired entries of all namespaces once the writes outnumber
+// the entries left by the previous sweep. It must be called with the write lock held.
+func (s *mapStore) written(n int) expiredEvents {
+	s.writes += n
+	if s.writes < s.sweepAt {
+		return nil
+	}
+
+	var events expiredEvents
+	now := time.Now().UnixNano()
+	live := 0
+	for ns, m := range s.m {
+		for key, e := range m {
+			if e.expireAt != 0 && e.expireAt <= now {
+				delete(m, key)
+				events = events.add(ns, Event{Kind: Expired, Key: key, Data: e.data}, s.listeners)
+			} else {
+				live++
+			}
+		}
+	}
+	s.writes = 0
+	s.sweepAt = live
+	if s.sweepAt < mapSweepMin {
+		s.sweepAt = mapSweepMin
+	}
+	return events
+}
+
+// expiredEvent is the event of an expired entry along with the subscribers of its namespace.
+type expiredEvent struct {
+	Event
+	listeners []func(Event)
+}
+
+// expiredEvents are notified after the lock is released.
+type expiredEvents []expiredEvent
+
+func (ev expiredEvents) add(ns string, e Event, listeners map[string][]func(Event)) expiredEvents {
+	if len(listeners[ns]) == 0 {
+		return ev
+	}
+	return append(ev, expiredEvent{Event: e, listeners: listeners[ns]})
+}
+
+func (ev expiredEvents) notify() {
+	for _, e := range ev {
+		for _, fn := range e.listeners {
+			fn(e.Event)
+		}
+	}
+}
+
+// Subscribe registers the function called for the expired entries of the namespace.
+func (s *mapStore) Subscribe(fn func(Event)) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.listeners[s.ns] = append(s.listeners[s.ns], fn)
+	return nil
+}
+
+// space returns the map of the namespace, it must be called with the write lock held.
+func (s *mapStore) space() map[string]mapEntry {
+	m, ok := s.m[s.ns]
+	if !ok {
+		m = make(map[string]mapEntry)
+		s.m[s.ns] = m
+	}
+	return m
+}
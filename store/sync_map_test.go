@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestMapStore(t *testing.T) {
@@ -41,3 +43,54 @@ func TestMapStore(t *testing.T) {
 	assert.Nil(t, b)
 	assert.True(t, errors.Is(err, ErrNotFound))
 }
+
+func TestMapStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	s := MapStore(0).(TTLStore)
+
+	err := s.SetWithTTL(ctx, "a", []byte{1}, 10*time.Millisecond)
+	assert.Nil(t, err)
+	err = s.SetWithTTL(ctx, "b", []byte{2}, 0)
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	time.Sleep(20 * time.Millisecond)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	b, err = s.Get(ctx, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{2})
+}
+
+func TestMapStore_Sweep(t *testing.T) {
+	ctx := context.Background()
+	s := MapStore(0)
+	users := Namespace(s, "users")
+	var expired int
+	assert.Nil(t, Subscribe(users, func(e Event) {
+		expired++
+	}))
+
+	// the expired entries are swept by the writes even if they are never read
+	for i := 0; i < mapSweepMin/2; i++ {
+		assert.Nil(t, SetWithTTL(ctx, s, strconv.Itoa(i), []byte{1}, time.Millisecond))
+		assert.Nil(t, SetWithTTL(ctx, users, strconv.Itoa(i), []byte{1}, time.Millisecond))
+	}
+	time.Sleep(5 * time.Millisecond)
+	entries := make([]Entry, mapSweepMin)
+	for i := range entries {
+		entries[i] = Entry{Key: "b" + strconv.Itoa(i), Data: []byte{2}}
+	}
+	assert.Nil(t, firstErr(SetMany(ctx, s, entries)))
+
+	m := s.(*mapStore)
+	assert.Equal(t, len(m.m[""]), mapSweepMin)
+	assert.Equal(t, len(m.m["users"]), 0)
+	assert.Equal(t, expired, mapSweepMin/2)
+}
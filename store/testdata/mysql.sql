@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `gcache_cache` (`key` VARBINARY(512) NOT NULL PRIMARY KEY, `data` LONGBLOB,
+		`expire_at` BIGINT NOT NULL DEFAULT 0, `namespace` VARBINARY(512) NOT NULL DEFAULT '',
+		INDEX `gcache_cache_namespace` (`namespace`), INDEX `gcache_cache_expire_at` (`expire_at`));
+
+SELECT `data` FROM `gcache_cache` WHERE `key` = ? AND (`expire_at` = 0 OR `expire_at` > ?)
+
+INSERT INTO `gcache_cache` (`key`, `data`, `expire_at`, `namespace`) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE `data` = VALUES(`data`), `expire_at` = VALUES(`expire_at`), `namespace` = VALUES(`namespace`)
+
+DELETE FROM `gcache_cache` WHERE `key` = ?
+
+DELETE FROM `gcache_cache`
+
+DELETE FROM `gcache_cache` WHERE `namespace` = ? OR (`namespace` > ? AND `namespace` < ?)
+
+DELETE FROM `gcache_cache` WHERE `expire_at` > 0 AND `expire_at` <= ?
+
+SELECT `key`, `data` FROM `gcache_cache` WHERE `key` IN (?,?,?) AND (`expire_at` = 0 OR `expire_at` > ?)
+
+DELETE FROM `gcache_cache` WHERE `key` IN (?,?,?)
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS "gcache_cache" ("key" TEXT COLLATE "C" NOT NULL PRIMARY KEY, "data" BYTEA,
+		"expire_at" BIGINT NOT NULL DEFAULT 0, "namespace" TEXT COLLATE "C" NOT NULL DEFAULT '');
+
+CREATE INDEX IF NOT EXISTS "gcache_cache_namespace" ON "gcache_cache" ("namespace");
+
+CREATE INDEX IF NOT EXISTS "gcache_cache_expire_at" ON "gcache_cache" ("expire_at") WHERE "expire_at" > 0;
+
+SELECT "data" FROM "gcache_cache" WHERE "key" = $1 AND ("expire_at" = 0 OR "expire_at" > $2)
+
+INSERT INTO "gcache_cache" ("key", "data", "expire_at", "namespace") VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("key") DO UPDATE SET "data" = EXCLUDED."data", "expire_at" = EXCLUDED."expire_at", "namespace" = EXCLUDED."namespace"
+
+DELETE FROM "gcache_cache" WHERE "key" = $1
+
+DELETE FROM "gcache_cache"
+
+DELETE FROM "gcache_cache" WHERE "namespace" = $1 OR ("namespace" > $2 AND "namespace" < $3)
+
+DELETE FROM "gcache_cache" WHERE "expire_at" > 0 AND "expire_at" <= $1
+
+SELECT "key", "data" FROM "gcache_cache" WHERE "key" IN ($1,$2,$3) AND ("expire_at" = 0 OR "expire_at" > $4)
+
+DELETE FROM "gcache_cache" WHERE "key" IN ($1,$2,$3)
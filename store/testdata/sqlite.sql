@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS "gcache_cache" ("key" TEXT NOT NULL PRIMARY KEY, "data" BLOB,
+		"expire_at" INTEGER NOT NULL DEFAULT 0, "namespace" TEXT NOT NULL DEFAULT '');
+
+CREATE INDEX IF NOT EXISTS "gcache_cache_namespace" ON "gcache_cache" ("namespace");
+
+CREATE INDEX IF NOT EXISTS "gcache_cache_expire_at" ON "gcache_cache" ("expire_at") WHERE "expire_at" > 0;
+
+SELECT "data" FROM "gcache_cache" WHERE "key" = ? AND ("expire_at" = 0 OR "expire_at" > ?)
+
+INSERT OR REPLACE INTO "gcache_cache" ("key", "data", "expire_at", "namespace") VALUES (?, ?, ?, ?)
+
+DELETE FROM "gcache_cache" WHERE "key" = ?
+
+DELETE FROM "gcache_cache"
+
+DELETE FROM "gcache_cache" WHERE "namespace" = ? OR ("namespace" > ? AND "namespace" < ?)
+
+DELETE FROM "gcache_cache" WHERE "expire_at" > 0 AND "expire_at" <= ?
+
+SELECT "key", "data" FROM "gcache_cache" WHERE "key" IN (?,?,?) AND ("expire_at" = 0 OR "expire_at" > ?)
+
+DELETE FROM "gcache_cache" WHERE "key" IN (?,?,?)
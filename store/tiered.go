@@ -0,0 +1,362 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// WriteMode defines how writes are propagated to the tiers of a tiered store.
+type WriteMode int
+
+const (
+	// WriteThrough writes to every tier before the write returns.
+	WriteThrough WriteMode = iota
+	// WriteBack writes to the first tier and propagates the write to the other tiers in background.
+	WriteBack
+)
+
+// ErrorPolicy defines how the failures of a tier are handled.
+type ErrorPolicy int
+
+const (
+	// FailOnError returns the error of the tier to the caller.
+	FailOnError ErrorPolicy = iota
+	// IgnoreErrors reports the error to OnError and continues as if the tier missed the key.
+	IgnoreErrors
+)
+
+// writeBackQueueSize is the default number of writes waiting to be propagated to the lower tiers.
+const writeBackQueueSize = 1024
+
+// defaultBackfillTTL is the time-to-live of the copies of the entries whose remaining time-to-live is not known.
+const defaultBackfillTTL = time.Minute
+
+// ErrStoreClosed indicates that the store was closed.
+var ErrStoreClosed = errors.New("store is closed")
+
+// Tier is a level of a tiered store.
+type Tier struct {
+	Store   Store
+	OnError ErrorPolicy
+}
+
+// TieredConfig configures a tiered store.
+type TieredConfig struct {
+	// Tiers are ordered from the fastest to the slowest.
+	Tiers []Tier
+	// WriteMode is WriteThrough by default.
+	WriteMode WriteMode
+	// WriteBackQueueSize limits the number of pending background writes.
+	WriteBackQueueSize int
+	// BackfillTTL limits the time-to-live of entries copied to the upper tiers on a lower tier hit.
+	// The copies never outlive the entries of the tiers implementing ExpiringStore, the copies of the entries
+	// of other tiers expire after BackfillTTL, or after a minute if it is zero.
+	BackfillTTL time.Duration
+	// OnError is called for the ignored errors and the failed background writes.
+	OnError func(tier int, err error)
+}
+
+type tieredOp func(ctx context.Context, s Store) error
+
+// tieredWrite is a write waiting to be applied to the lower tiers.
+type tieredWrite struct {
+	tiers []Tier
+	op    tieredOp
+}
+
+// writeBackQueue is shared by the tiered store and its namespaces.
+type writeBackQueue struct {
+	mx     sync.RWMutex
+	closed bool
+	ops    chan tieredWrite
+	wg     sync.WaitGroup
+}
+
+type tieredStore struct {
+	cfg   TieredConfig
+	queue *writeBackQueue
+}
+
+// Tiered creates a write-through store composed of the stores ordered from the fastest to the slowest.
+func Tiered(stores ...Store) Store {
+	tiers := make([]Tier, len(stores))
+	for i, s := range stores {
+		tiers[i] = Tier{Store: s}
+	}
+	return TieredStore(TieredConfig{Tiers: tiers})
+}
+
+// TieredStore creates a store that reads through the tiers in order and copies found entries to the upper tiers.
+// In WriteBack mode the store implements io.Closer, Close waits for the pending writes.
+// The store does not report events, an entry evicted by a tier is still read from the lower tiers.
+func TieredStore(cfg TieredConfig) Store {
+	s := &tieredStore{cfg: cfg}
+	if cfg.WriteMode == WriteBack {
+		size := cfg.WriteBackQueueSize
+		if size <= 0 {
+			size = writeBackQueueSize
+		}
+		s.queue = &writeBackQueue{ops: make(chan tieredWrite, size)}
+		s.queue.wg.Add(1)
+		go s.writeBack()
+	}
+	return s
+}
+
+func (s *tieredStore) Get(ctx context.Context, key string) ([]byte, error) {
+	for i := range s.cfg.Tiers {
+		v, ttl, err := s.get(ctx, i, key)
+		if err == nil {
+			s.backfill(ctx, i, key, v, ttl)
+			return v, nil
+		}
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err = s.handle(i, err); err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *tieredStore) Set(ctx context.Context, key string, data []byte) error {
+	return s.SetWithTTL(ctx, key, data, 0)
+}
+
+func (s *tieredStore) SetWithTTL(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return s.write(ctx, func(ctx context.Context, st Store) error {
+		return SetWithTTL(ctx, st, key, data, ttl)
+	})
+}
+
+func (s *tieredStore) Delete(ctx context.Context, key string) error {
+	return s.write(ctx, func(ctx context.Context, st Store) error {
+		return st.Delete(ctx, key)
+	})
+}
+
+func (s *tieredStore) Clear(ctx context.Context) error {
+	return s.write(ctx, func(ctx context.Context, st Store) error {
+		return st.Clear(ctx)
+	})
+}
+
+func (s *tieredStore) GetMany(ctx context.Context, keys []string) ([][]byte, []error) {
+	data := make([][]byte, len(keys))
+	errs := repeatErr(ErrNotFound, len(keys))
+
+	// indexes of the keys that are still missing
+	missing := make([]int, len(keys))
+	for i := range missing {
+		missing[i] = i
+	}
+
+	for n := range s.cfg.Tiers {
+		if len(missing) == 0 {
+			break
+		}
+
+		tierKeys := make([]string, len(missing))
+		for j, i := range missing {
+			tierKeys[j] = keys[i]
+		}
+
+		var found []Entry
+		tierData, tierTTLs, tierErrs := s.getMany(ctx, n, tierKeys)
+		next := missing[:0]
+		for j, i := range missing {
+			err := tierErrs[j]
+			switch {
+			case err == nil:
+				data[i], errs[i] = tierData[j], nil
+				if ttl, ok := s.backfillTTL(tierTTLs[j]); ok {
+					found = append(found, Entry{Key: keys[i], Data: tierData[j], TTL: ttl})
+				}
+			case errors.Is(err, ErrNotFound):
+				next = append(next, i)
+			default:
+				if err = s.handle(n, err); err != nil {
+					errs[i] = err
+				} else {
+					next = append(next, i)
+				}
+			}
+		}
+		missing = next
+
+		if len(found) > 0 {
+			s.backfillMany(ctx, n, found)
+		}
+	}
+	return data, errs
+}
+
+// SetMany fails all entries on the tier that could not set any of them.
+func (s *tieredStore) SetMany(ctx context.Context, entries []Entry) []error {
+	err := s.write(ctx, func(ctx context.Context, st Store) error {
+		return firstErr(SetMany(ctx, st, entries))
+	})
+	return repeatErr(err, len(entries))
+}
+
+// DeleteMany fails all keys on the tier that could not delete any of them.
+func (s *tieredStore) DeleteMany(ctx context.Context, keys []string) []error {
+	err := s.write(ctx, func(ctx context.Context, st Store) error {
+		return firstErr(DeleteMany(ctx, st, keys))
+	})
+	return repeatErr(err, len(keys))
+}
+
+// Namespace returns the tiered store of the namespaces of every tier. In WriteBack mode
+// the namespace shares the background writes with the parent store and is closed along with it.
+func (s *tieredStore) Namespace(name string) Store {
+	cfg := s.cfg
+	cfg.Tiers = make([]Tier, len(s.cfg.Tiers))
+	for i, t := range s.cfg.Tiers {
+		cfg.Tiers[i] = Tier{Store: Namespace(t.Store, name), OnError: t.OnError}
+	}
+	return &tieredStore{cfg: cfg, queue: s.queue}
+}
+
+// Close waits for the pending background writes and stops the background worker.
+func (s *tieredStore) Close() error {
+	if s.queue == nil {
+		return nil
+	}
+
+	q := s.queue
+	q.mx.Lock()
+	if q.closed {
+		q.mx.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.ops)
+	q.mx.Unlock()
+
+	q.wg.Wait()
+	return nil
+}
+
+// write applies the operation to the tiers from the slowest to the fastest, so that an upper tier
+// never has the data that a lower tier has not. In WriteBack mode only the first tier is written synchronously.
+func (s *tieredStore) write(ctx context.Context, op tieredOp) error {
+	if s.cfg.WriteMode == WriteBack && len(s.cfg.Tiers) > 0 {
+		s.queue.mx.RLock()
+		defer s.queue.mx.RUnlock()
+		if s.queue.closed {
+			return ErrStoreClosed
+		}
+
+		if err := s.apply(ctx, 0, op); err != nil {
+			return err
+		}
+		return s.enqueue(ctx, op)
+	}
+
+	for i := len(s.cfg.Tiers) - 1; i >= 0; i-- {
+		if err := s.apply(ctx, i, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *tieredStore) apply(ctx context.Context, i int, op tieredOp) error {
+	if err := op(ctx, s.cfg.Tiers[i].Store); err != nil {
+		return s.handle(i, err)
+	}
+	return nil
+}
+
+func (s *tieredStore) enqueue(ctx context.Context, op tieredOp) error {
+	select {
+	case s.queue.ops <- tieredWrite{tiers: s.cfg.Tiers, op: op}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *tieredStore) writeBack() {
+	defer s.queue.wg.Done()
+	ctx := context.Background()
+	for w := range s.queue.ops {
+		for i := len(w.tiers) - 1; i > 0; i-- {
+			if err := w.op(ctx, w.tiers[i].Store); err != nil {
+				s.report(i, err)
+			}
+		}
+	}
+}
+
+// get reads the entry of the tier n and its remaining time-to-live, which is not needed for the first tier
+// as it is never backfilled.
+func (s *tieredStore) get(ctx context.Context, n int, key string) ([]byte, time.Duration, error) {
+	if n == 0 {
+		data, err := s.cfg.Tiers[0].Store.Get(ctx, key)
+		return data, 0, err
+	}
+	return getWithTTL(ctx, s.cfg.Tiers[n].Store, key)
+}
+
+func (s *tieredStore) getMany(ctx context.Context, n int, keys []string) ([][]byte, []time.Duration, []error) {
+	if n == 0 {
+		data, errs := GetMany(ctx, s.cfg.Tiers[0].Store, keys)
+		return data, make([]time.Duration, len(keys)), errs
+	}
+	return getManyWithTTL(ctx, s.cfg.Tiers[n].Store, keys)
+}
+
+// backfillTTL returns the time-to-live of the copy of an entry, whose remaining time-to-live is unknownTTL
+// if the tier does not report it. It reports false if the entry has expired, so it must not be copied.
+func (s *tieredStore) backfillTTL(ttl time.Duration) (time.Duration, bool) {
+	switch {
+	case ttl == unknownTTL && s.cfg.BackfillTTL <= 0:
+		return defaultBackfillTTL, true
+	case ttl < 0 && ttl != unknownTTL:
+		return 0, false
+	case ttl > 0 && (s.cfg.BackfillTTL <= 0 || ttl < s.cfg.BackfillTTL):
+		return ttl, true
+	}
+	return s.cfg.BackfillTTL, true
+}
+
+// backfill copies the entry found in the tier n to the upper tiers. The failures are only reported.
+func (s *tieredStore) backfill(ctx context.Context, n int, key string, data []byte, ttl time.Duration) {
+	ttl, ok := s.backfillTTL(ttl)
+	if !ok {
+		return
+	}
+	for i := n - 1; i >= 0; i-- {
+		if err := SetWithTTL(ctx, s.cfg.Tiers[i].Store, key, data, ttl); err != nil {
+			s.report(i, err)
+		}
+	}
+}
+
+func (s *tieredStore) backfillMany(ctx context.Context, n int, entries []Entry) {
+	for i := n - 1; i >= 0; i-- {
+		if err := firstErr(SetMany(ctx, s.cfg.Tiers[i].Store, entries)); err != nil {
+			s.report(i, err)
+		}
+	}
+}
+
+// handle returns the error if the tier policy is FailOnError, otherwise the error is reported and ignored.
+func (s *tieredStore) handle(i int, err error) error {
+	if s.cfg.Tiers[i].OnError == FailOnError {
+		return err
+	}
+	s.report(i, err)
+	return nil
+}
+
+func (s *tieredStore) report(i int, err error) {
+	if s.cfg.OnError != nil {
+		s.cfg.OnError(i, err)
+	}
+}
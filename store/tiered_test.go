@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/allegro/bigcache/v3"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("store is unavailable")
+
+type unavailableStore struct{}
+
+func (unavailableStore) Get(context.Context, string) ([]byte, error) { return nil, errUnavailable }
+func (unavailableStore) Set(context.Context, string, []byte) error   { return errUnavailable }
+func (unavailableStore) Delete(context.Context, string) error        { return errUnavailable }
+func (unavailableStore) Clear(context.Context) error                 { return errUnavailable }
+
+func TestTieredStore(t *testing.T) {
+	ctx := context.Background()
+	s := Tiered(MapStore(0), MapStore(0))
+
+	key := "a"
+	err := s.Set(ctx, key, nil)
+	assert.Nil(t, err)
+
+	key = "b"
+	err = s.Set(ctx, key, []byte{1, 2, 3})
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1, 2, 3})
+
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	// repeated delete must be safe
+	err = s.Delete(ctx, key)
+	assert.Nil(t, err)
+
+	b, err = s.Get(ctx, key)
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = s.Clear(ctx)
+	assert.Nil(t, err)
+
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestTieredStore_Backfill(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := MapStore(0), MapStore(0)
+	s := TieredStore(TieredConfig{
+		Tiers:       []Tier{{Store: l1}, {Store: l2}},
+		BackfillTTL: 10 * time.Millisecond,
+	})
+
+	err := l2.Set(ctx, "a", []byte{1})
+	assert.Nil(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	b, err = l1.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	// the backfilled entry expires in the upper tier only
+	time.Sleep(20 * time.Millisecond)
+	_, err = l1.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = l2.Get(ctx, "a")
+	assert.Nil(t, err)
+}
+
+func TestTieredStore_BackfillRemainingTTL(t *testing.T) {
+	ctx := context.Background()
+	l1 := BoundedStore(BoundedConfig{MaxEntries: 1})
+	l2 := MapStore(0)
+	s := Tiered(l1, l2)
+
+	assert.Nil(t, SetWithTTL(ctx, s, "a", []byte{1}, 50*time.Millisecond))
+	assert.Nil(t, SetWithTTL(ctx, s, "b", []byte{2}, 50*time.Millisecond))
+	assert.Nil(t, s.Set(ctx, "c", []byte{3}))
+
+	// the entries evicted from the first tier are backfilled with their remaining time-to-live
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	data, errs := GetMany(ctx, s, []string{"b"})
+	assert.Equal(t, errs, []error{nil})
+	assert.Equal(t, data, [][]byte{{2}})
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = l1.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// the entries without expiration are copied without expiration
+	b, err = s.Get(ctx, "c")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{3})
+	_, ttl, err := GetWithTTL(ctx, l1, "c")
+	assert.Nil(t, err)
+	assert.Equal(t, ttl, time.Duration(0))
+}
+
+func TestTieredStore_BackfillUnknownTTL(t *testing.T) {
+	ctx := context.Background()
+	bc, err := bigcache.NewBigCache(bigcache.DefaultConfig(10 * time.Minute))
+	assert.Nil(t, err)
+	l1, l2 := MapStore(0), BigcacheStore(bc)
+	s := Tiered(l1, l2)
+
+	assert.Nil(t, l2.Set(ctx, "a", []byte{1}))
+	_, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+
+	// the copy of the entry of a tier that does not report the time-to-live expires
+	_, ttl, err := GetWithTTL(ctx, l1, "a")
+	assert.Nil(t, err)
+	assert.InDelta(t, ttl, defaultBackfillTTL, float64(time.Second))
+}
+
+// expiringStore reports the same remaining time-to-live of every entry.
+type expiringStore struct {
+	TTLStore
+	ttl time.Duration
+}
+
+func (s expiringStore) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	data, err := s.Get(ctx, key)
+	return data, s.ttl, err
+}
+
+func TestTieredStore_BackfillExpired(t *testing.T) {
+	ctx := context.Background()
+	l1 := MapStore(0)
+	l2 := expiringStore{TTLStore: MapStore(0).(TTLStore), ttl: -time.Millisecond}
+	s := Tiered(l1, l2)
+
+	// the entry that expired while it was read is not copied
+	assert.Nil(t, l2.Set(ctx, "a", []byte{1}))
+	_, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	_, errs := GetMany(ctx, s, []string{"a"})
+	assert.Equal(t, errs, []error{nil})
+	_, err = l1.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// an expiring entry is read with its remaining time-to-live, or not found
+	m := MapStore(0)
+	assert.Nil(t, SetWithTTL(ctx, m, "a", []byte{1}, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	_, _, err = GetWithTTL(ctx, m, "a")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	ttl, ok := remaining(time.Now().UnixNano())
+	assert.False(t, ok)
+	assert.LessOrEqual(t, ttl, time.Duration(0))
+}
+
+func TestTieredStore_WriteThrough(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := MapStore(0), MapStore(0)
+	s := Tiered(l1, l2)
+
+	err := s.(TTLStore).SetWithTTL(ctx, "a", []byte{1}, time.Minute)
+	assert.Nil(t, err)
+	err = s.Set(ctx, "b", []byte{2})
+	assert.Nil(t, err)
+
+	for _, tier := range []Store{l1, l2} {
+		b, err := tier.Get(ctx, "a")
+		assert.Nil(t, err)
+		assert.Equal(t, b, []byte{1})
+	}
+
+	err = s.Delete(ctx, "a")
+	assert.Nil(t, err)
+	for _, tier := range []Store{l1, l2} {
+		_, err = tier.Get(ctx, "a")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+
+	err = s.Clear(ctx)
+	assert.Nil(t, err)
+	for _, tier := range []Store{l1, l2} {
+		_, err = tier.Get(ctx, "b")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+}
+
+func TestTieredStore_ErrorPolicy(t *testing.T) {
+	ctx := context.Background()
+	l1 := MapStore(0)
+
+	var reported []int
+	s := TieredStore(TieredConfig{
+		Tiers: []Tier{{Store: l1}, {Store: unavailableStore{}, OnError: IgnoreErrors}},
+		OnError: func(tier int, err error) {
+			assert.True(t, errors.Is(err, errUnavailable))
+			reported = append(reported, tier)
+		},
+	})
+
+	// the unavailable tier degrades to the first tier only
+	err := s.Set(ctx, "a", []byte{1})
+	assert.Nil(t, err)
+	b, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	_, err = s.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Nil(t, s.Delete(ctx, "a"))
+	assert.Nil(t, s.Clear(ctx))
+	assert.Equal(t, reported, []int{1, 1, 1, 1})
+
+	s = Tiered(l1, unavailableStore{})
+	assert.True(t, errors.Is(s.Set(ctx, "a", []byte{1}), errUnavailable))
+	_, err = s.Get(ctx, "a")
+	assert.True(t, errors.Is(err, errUnavailable))
+	assert.True(t, errors.Is(s.Delete(ctx, "a"), errUnavailable))
+	assert.True(t, errors.Is(s.Clear(ctx), errUnavailable))
+}
+
+func TestTieredStore_WriteBack(t *testing.T) {
+	ctx := context.Background()
+	l1, l2 := MapStore(0), MapStore(0)
+	s := TieredStore(TieredConfig{
+		Tiers:     []Tier{{Store: l1}, {Store: l2}},
+		WriteMode: WriteBack,
+	})
+
+	err := s.Set(ctx, "a", []byte{1})
+	assert.Nil(t, err)
+	err = s.Set(ctx, "b", []byte{2})
+	assert.Nil(t, err)
+	err = s.Delete(ctx, "b")
+	assert.Nil(t, err)
+
+	b, err := l1.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+
+	// close waits for the pending writes
+	c, ok := s.(io.Closer)
+	assert.True(t, ok)
+	assert.Nil(t, c.Close())
+	assert.Nil(t, c.Close())
+
+	b, err = l2.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, b, []byte{1})
+	_, err = l2.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	err = s.Set(ctx, "c", []byte{3})
+	assert.True(t, errors.Is(err, ErrStoreClosed))
+}